@@ -0,0 +1,51 @@
+// Package analysis describes the smells that rikki-'s analyzers can detect.
+//
+// Each analyzer package exposes a Catalog of its smells, so that tooling
+// can document them and check that every one of them has a comment.
+package analysis
+
+import "sort"
+
+// Severity is how much a smell gets in the way of a solution.
+type Severity string
+
+const (
+	// Info is a matter of taste or idiom.
+	Info Severity = "info"
+	// Warning is something most reviewers would ask to have fixed.
+	Warning Severity = "warning"
+	// Blocking means the code is likely broken, or can't be reviewed as it is.
+	Blocking Severity = "blocking"
+)
+
+// Smell is something an analyzer can detect in a solution.
+// The Key names the comment that rikki- posts about it.
+type Smell struct {
+	Key         string
+	Description string
+	Severity    Severity
+	Example     string
+}
+
+// Catalog is every smell an analyzer can detect.
+type Catalog []Smell
+
+// Keys lists the key of every smell in the catalog, sorted.
+func (c Catalog) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for _, smell := range c {
+		keys = append(keys, smell.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Lookup finds a smell by its key.
+func (c Catalog) Lookup(key string) (Smell, bool) {
+	for _, smell := range c {
+		if smell.Key == key {
+			return smell, true
+		}
+	}
+	return Smell{}, false
+}
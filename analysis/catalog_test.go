@@ -0,0 +1,24 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	c := Catalog{
+		{Key: "gofmt", Severity: Warning},
+		{Key: "go-vet", Severity: Blocking},
+	}
+
+	if got, want := c.Keys(), []string{"go-vet", "gofmt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys - got: %s, want: %s", got, want)
+	}
+	smell, ok := c.Lookup("go-vet")
+	if !ok || smell.Severity != Blocking {
+		t.Errorf("lookup - got: %#v, %t", smell, ok)
+	}
+	if _, ok := c.Lookup("stub"); ok {
+		t.Error("lookup - found a smell that isn't in the catalog")
+	}
+}
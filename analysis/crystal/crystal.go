@@ -8,15 +8,33 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/exercism/rikki/analysis"
 )
 
 // Host is the base URL for the crystal-analyzer API.
 // Path is the endpoint for testing a file (default: "check").
+// Client calls it, with a timeout so that a slow analyzer doesn't hold up jobs.
 var (
-	Host string
-	Path = "check"
+	Host   string
+	Path   = "check"
+	Client = &http.Client{Timeout: 10 * time.Second}
 )
 
+// Version is part of the key for cached analyses of crystal code.
+const Version = "1"
+
+// Catalog describes the problems the crystal-analyzer checks for.
+var Catalog = analysis.Catalog{
+	{
+		Key:         "unformatted",
+		Description: "The code isn't formatted with crystal tool format.",
+		Severity:    analysis.Warning,
+		Example:     "def hello( name )\n  \"Hello, #{name}!\"\nend",
+	},
+}
+
 type request struct {
 	ID       string `json:"id"`
 	Contents string `json:"contents"`
@@ -54,7 +72,7 @@ func Analyze(_ string, files map[string]string) ([]string, error) {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -3,7 +3,12 @@ package crystal
 import (
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/exercism/rikki/analysis/crystal/crystaltest"
 )
 
 func TestAnalyze(t *testing.T) {
@@ -51,3 +56,107 @@ func TestAnalyze(t *testing.T) {
 		}
 	}
 }
+
+func TestAnalyzeWithStub(t *testing.T) {
+	tests := []struct {
+		desc     string
+		problems []crystaltest.Problem
+		smells   []string
+	}{
+		{"no problems", nil, []string{}},
+		{"formatted", []crystaltest.Problem{{Type: "unformatted", Result: false}}, []string{}},
+		{"unformatted", []crystaltest.Problem{{Type: "unformatted", Result: true}}, []string{"unformatted"}},
+		{
+			"several checks",
+			[]crystaltest.Problem{
+				{Type: "unformatted", Result: true},
+				{Type: "shadowing", Result: false},
+				{Type: "long-method", Result: true},
+			},
+			[]string{"unformatted", "long-method"},
+		},
+	}
+
+	for _, test := range tests {
+		ts := crystaltest.NewServer()
+		ts.Respond(test.problems...)
+		Host, Path = ts.URL, "check"
+
+		smells, err := Analyze("", map[string]string{"hello_world.cr": "code"})
+		ts.Close()
+		if err != nil {
+			t.Errorf("%s: %s", test.desc, err)
+			continue
+		}
+		if smells == nil {
+			smells = []string{}
+		}
+		if !reflect.DeepEqual(smells, test.smells) {
+			t.Errorf("%s: got %v, want %v", test.desc, smells, test.smells)
+		}
+	}
+}
+
+func TestAnalyzeRequest(t *testing.T) {
+	ts := crystaltest.NewServer()
+	defer ts.Close()
+	Host, Path = ts.URL, "check"
+
+	files := map[string]string{
+		"hello_world.cr": "module HelloWorld\nend",
+		"helper.cr":      "module Helper\nend",
+	}
+	if _, err := Analyze("hello-world", files); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := ts.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if requests[0].ID != "rikki" {
+		t.Errorf("id - got: %q, want: %q", requests[0].ID, "rikki")
+	}
+	for name, code := range files {
+		if !strings.Contains(requests[0].Contents, code) {
+			t.Errorf("code from %s was not submitted", name)
+		}
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		setup func(*crystaltest.Server)
+	}{
+		{"error payload", func(s *crystaltest.Server) { s.RespondError("syntax error") }},
+		{"server error", func(s *crystaltest.Server) { s.RespondStatus(http.StatusBadGateway, "") }},
+	}
+
+	for _, test := range tests {
+		ts := crystaltest.NewServer()
+		test.setup(ts)
+		Host, Path = ts.URL, "check"
+
+		_, err := Analyze("", map[string]string{"hello_world.cr": "code"})
+		ts.Close()
+		if err == nil {
+			t.Errorf("%s: expected an error", test.desc)
+		}
+	}
+}
+
+func TestAnalyzeLatency(t *testing.T) {
+	ts := crystaltest.NewServer()
+	defer ts.Close()
+	ts.SetLatency(50 * time.Millisecond)
+	Host, Path = ts.URL, "check"
+
+	start := time.Now()
+	if _, err := Analyze("", map[string]string{"hello_world.cr": "code"}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("response came back after %s, expected the stub to be slow", elapsed)
+	}
+}
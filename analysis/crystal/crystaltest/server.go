@@ -0,0 +1,123 @@
+// Package crystaltest provides a stub of the crystal-analyzer API for testing offline.
+//
+// The stub speaks the /check JSON contract. It responds with canned
+// problems or errors, can be slowed down, and records the requests it receives.
+package crystaltest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Problem is a check the analyzer ran, and whether the code has that problem.
+type Problem struct {
+	Type   string
+	Result bool
+}
+
+// Request is what the analyzer received.
+type Request struct {
+	ID       string `json:"id"`
+	Contents string `json:"contents"`
+}
+
+// Server is a stub crystal-analyzer.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	problems []Problem
+	errMsg   string
+	status   int
+	body     string
+	latency  time.Duration
+	requests []Request
+}
+
+// NewServer starts a stub analyzer that finds nothing. Close it when done.
+func NewServer() *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/check", s.check)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Respond makes the analyzer report the problems.
+func (s *Server) Respond(problems ...Problem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.problems, s.errMsg, s.status = problems, "", 0
+}
+
+// RespondError makes the analyzer report an error in the payload.
+func (s *Server) RespondError(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.problems, s.errMsg, s.status = nil, msg, 0
+}
+
+// RespondStatus makes the analyzer fail with an HTTP status and body.
+func (s *Server) RespondStatus(status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.body = status, body
+}
+
+// SetLatency delays every response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) check(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	problems, errMsg, status, body, latency := s.problems, s.errMsg, s.status, s.body, s.latency
+	s.mu.Unlock()
+
+	time.Sleep(latency)
+
+	if status != 0 {
+		http.Error(w, body, status)
+		return
+	}
+
+	// The analyzer sends results as strings, "true" or "false".
+	type problem struct {
+		Type   string `json:"type"`
+		Result string `json:"result"`
+	}
+	payload := struct {
+		ID       string    `json:"id"`
+		Problems []problem `json:"problems"`
+		Error    string    `json:"error"`
+	}{ID: req.ID, Problems: []problem{}, Error: errMsg}
+	for _, p := range problems {
+		payload.Problems = append(payload.Problems, problem{p.Type, strconv.FormatBool(p.Result)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payload)
+}
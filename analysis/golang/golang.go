@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/exercism/rikki/analysis"
 	"github.com/golang/lint"
 )
 
@@ -32,6 +33,86 @@ const (
 	msgPkgCommentWrong = `package comment should be of the form`
 )
 
+// Version identifies the detectors. Bump it when a change to them could
+// find different smells in the same code.
+const Version = "1"
+
+// Catalog describes every smell Analyze can detect.
+var Catalog = analysis.Catalog{
+	{
+		Key:         smellFmt,
+		Description: "The code isn't formatted with gofmt.",
+		Severity:    analysis.Warning,
+		Example:     "func ok() {\n\tprintln(3 % 2 == 0)\n}",
+	},
+	{
+		Key:         smellVet,
+		Description: "go vet reports suspicious constructs, such as unreachable code.",
+		Severity:    analysis.Blocking,
+		Example:     "func ok() bool {\n\treturn true\n\treturn false\n}",
+	},
+	{
+		Key:         smellStub,
+		Description: "A comment from the stub file was left in.",
+		Severity:    analysis.Info,
+		Example:     "// This is a stub file.\npackage leap",
+	},
+	{
+		Key:         smellBuild,
+		Description: "The build constraint that keeps the example solution out of the tests was copied over.",
+		Severity:    analysis.Info,
+		Example:     "// +build !example\n\npackage leap",
+	},
+	{
+		Key:         smellCase,
+		Description: "A name uses underscores or ALL_CAPS instead of mixedCaps.",
+		Severity:    analysis.Warning,
+		Example:     "var trimmed_remark = strings.TrimSpace(remark)",
+	},
+	{
+		Key:         smellZero,
+		Description: "A variable is explicitly initialized to its zero value.",
+		Severity:    analysis.Info,
+		Example:     "var count int = 0",
+	},
+	{
+		Key:         smellElse,
+		Description: "An else follows an if block that ends in a return.",
+		Severity:    analysis.Info,
+		Example:     "if ok {\n\treturn 1\n} else {\n\treturn 2\n}",
+	},
+	{
+		Key:         smellInstance,
+		Description: "A comment talks about an instance, which Go doesn't have.",
+		Severity:    analysis.Info,
+		Example:     "// New returns an instance of Clock.",
+	},
+	{
+		Key:         smellObject,
+		Description: "A comment talks about an object, which Go doesn't have.",
+		Severity:    analysis.Info,
+		Example:     "// New creates a Clock object.",
+	},
+	{
+		Key:         smellReceiverName,
+		Description: "The methods of a type name their receiver differently.",
+		Severity:    analysis.Warning,
+		Example:     "func (b Bacterium) Grow() {}\nfunc (bac Bacterium) Split() {}",
+	},
+	{
+		Key:         smellRangeLoop,
+		Description: "A range loop assigns the second value to the blank identifier.",
+		Severity:    analysis.Info,
+		Example:     "for i, _ := range expected {",
+	},
+	{
+		Key:         smellCommentFormat,
+		Description: "A package or doc comment doesn't start with the name of the thing it documents.",
+		Severity:    analysis.Info,
+		Example:     "// Converts units used in recipes.\npackage cook",
+	},
+}
+
 var (
 	rgxStub            = regexp.MustCompile(`\bstub\b`)
 	rgxDocCommentWrong = regexp.MustCompile(`comment on exported.*should be of the form`)
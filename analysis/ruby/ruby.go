@@ -10,11 +10,68 @@ import (
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/exercism/rikki/analysis"
 )
 
 // Host is the base URL for the Ruby analyzer API.
 var Host string
 
+// Client calls the Ruby analyzer API. Its timeout keeps a slow analyzer
+// from holding up jobs indefinitely.
+var Client = &http.Client{Timeout: 10 * time.Second}
+
+// Version is part of the key for cached analyses of ruby code.
+const Version = "1"
+
+// Catalog describes the smells the ruby-analyzer is known to report.
+// Their keys are <type>/<key>.
+var Catalog = analysis.Catalog{
+	{
+		Key:         "control_flow/logical",
+		Description: "and, or or not is used as a logical operator.",
+		Severity:    analysis.Warning,
+		Example:     "hungry? and thirsty?",
+	},
+	{
+		Key:         "enumerable_condition/enumerable_condition",
+		Description: "A loop over a collection has a condition that an Enumerable method could take care of.",
+		Severity:    analysis.Info,
+		Example:     "numbers.each do |n|\n  evens << n if n.even?\nend",
+	},
+	{
+		Key:         "for_loop/for_loop",
+		Description: "A for loop is used instead of an iterator method.",
+		Severity:    analysis.Warning,
+		Example:     "for i in 0...a.length\n  count += 1 if a[i] != b[i]\nend",
+	},
+	{
+		Key:         "indentation/inconsistent_spacing",
+		Description: "The code is indented by varying amounts.",
+		Severity:    analysis.Warning,
+		Example:     "def hey\n  if silent?\n     'Fine.'\n  end\nend",
+	},
+	{
+		Key:         "indentation/tab",
+		Description: "The code is indented with tabs.",
+		Severity:    analysis.Warning,
+		Example:     "def hey\n\t'Whatever.'\nend",
+	},
+	{
+		Key:         "indentation/two_spaces",
+		Description: "The code isn't indented with two spaces.",
+		Severity:    analysis.Warning,
+		Example:     "def hey\n    'Whatever.'\nend",
+	},
+	{
+		Key:         "shebang/shebang",
+		Description: "The file starts with a shebang, as if it were a script.",
+		Severity:    analysis.Info,
+		Example:     "#!/usr/bin/env ruby\nclass Bob\nend",
+	},
+}
+
 type result struct {
 	Type string   `json:"type"`
 	Keys []string `json:"keys"`
@@ -47,7 +104,7 @@ func Analyze(slug string, files map[string]string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,138 @@
+package ruby
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exercism/rikki/analysis/ruby/rubytest"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		desc    string
+		results []rubytest.Result
+		smells  []string
+	}{
+		{
+			"nothing found",
+			nil,
+			[]string{},
+		},
+		{
+			"one violation",
+			[]rubytest.Result{{Type: "for_loop", Keys: []string{"for_loop"}}},
+			[]string{"for_loop/for_loop"},
+		},
+		{
+			"several violations",
+			[]rubytest.Result{
+				{Type: "indentation", Keys: []string{"tab", "inconsistent_spacing"}},
+				{Type: "shebang", Keys: []string{"shebang"}},
+			},
+			[]string{"indentation/inconsistent_spacing", "indentation/tab", "shebang/shebang"},
+		},
+		{
+			"type without keys",
+			[]rubytest.Result{{Type: "control_flow", Keys: []string{}}},
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		ts := rubytest.NewServer()
+		ts.Respond(test.results...)
+		Host = ts.URL
+
+		smells, err := Analyze("two-fer", map[string]string{"two_fer.rb": "code"})
+		ts.Close()
+		if err != nil {
+			t.Errorf("%s: %s", test.desc, err)
+			continue
+		}
+
+		// The smells are shuffled, so compare them in order.
+		sort.Strings(smells)
+		if smells == nil {
+			smells = []string{}
+		}
+		if !reflect.DeepEqual(smells, test.smells) {
+			t.Errorf("%s: got %v, want %v", test.desc, smells, test.smells)
+		}
+	}
+}
+
+func TestAnalyzeSubmitsAllFiles(t *testing.T) {
+	ts := rubytest.NewServer()
+	defer ts.Close()
+	Host = ts.URL
+
+	files := map[string]string{
+		"two_fer.rb": "class TwoFer\nend",
+		"helper.rb":  "module Helper\nend",
+	}
+	if _, err := Analyze("two-fer", files); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := ts.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	for name, code := range files {
+		if !strings.Contains(requests[0], code) {
+			t.Errorf("code from %s was not submitted", name)
+		}
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		setup func(*rubytest.Server)
+	}{
+		{"error payload", func(s *rubytest.Server) { s.RespondError("unable to parse code") }},
+		{"server error", func(s *rubytest.Server) { s.RespondStatus(http.StatusInternalServerError, "boom") }},
+		{"not found", func(s *rubytest.Server) { s.RespondStatus(http.StatusNotFound, "") }},
+	}
+
+	for _, test := range tests {
+		ts := rubytest.NewServer()
+		test.setup(ts)
+		Host = ts.URL
+
+		_, err := Analyze("two-fer", map[string]string{"two_fer.rb": "code"})
+		ts.Close()
+		if err == nil {
+			t.Errorf("%s: expected an error", test.desc)
+		}
+	}
+
+	Host = "http://127.0.0.1:0"
+	if _, err := Analyze("two-fer", map[string]string{"two_fer.rb": "code"}); err == nil {
+		t.Error("unreachable analyzer: expected an error")
+	}
+}
+
+func TestAnalyzeLatency(t *testing.T) {
+	ts := rubytest.NewServer()
+	defer ts.Close()
+	ts.SetLatency(50 * time.Millisecond)
+	ts.Respond(rubytest.Result{Type: "shebang", Keys: []string{"shebang"}})
+	Host = ts.URL
+
+	start := time.Now()
+	smells, err := Analyze("two-fer", map[string]string{"two_fer.rb": "code"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("response came back after %s, expected the stub to be slow", elapsed)
+	}
+	if len(smells) != 1 {
+		t.Errorf("got %d smells, want 1", len(smells))
+	}
+}
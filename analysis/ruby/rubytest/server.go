@@ -0,0 +1,111 @@
+// Package rubytest provides a stub of the ruby-analyzer API for testing offline.
+//
+// The stub speaks the /analyze/ruby JSON contract. It responds with canned
+// results or errors, can be slowed down, and records the code it receives.
+package rubytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Result is a violation reported by the analyzer: a type, and the keys
+// of the specific problems found.
+type Result struct {
+	Type string   `json:"type"`
+	Keys []string `json:"keys"`
+}
+
+// Server is a stub ruby-analyzer.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	results []Result
+	errMsg  string
+	status  int
+	body    string
+	latency time.Duration
+	codes   []string
+}
+
+// NewServer starts a stub analyzer that finds nothing. Close it when done.
+func NewServer() *Server {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyze/ruby", s.analyze)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Respond makes the analyzer report the results.
+func (s *Server) Respond(results ...Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results, s.errMsg, s.status = results, "", 0
+}
+
+// RespondError makes the analyzer report an error in the payload.
+func (s *Server) RespondError(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results, s.errMsg, s.status = nil, msg, 0
+}
+
+// RespondStatus makes the analyzer fail with an HTTP status and body.
+func (s *Server) RespondStatus(status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.body = status, body
+}
+
+// SetLatency delays every response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests returns the code submitted in each request so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.codes...)
+}
+
+func (s *Server) analyze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.codes = append(s.codes, req.Code)
+	results, errMsg, status, body, latency := s.results, s.errMsg, s.status, s.body, s.latency
+	s.mu.Unlock()
+
+	time.Sleep(latency)
+
+	if status != 0 {
+		http.Error(w, body, status)
+		return
+	}
+	if results == nil {
+		results = []Result{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Results []Result `json:"results"`
+		Error   string   `json:"error,omitempty"`
+	}{results, errMsg})
+}
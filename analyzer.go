@@ -1,15 +1,17 @@
 package main
 
 import (
-	"log"
-	"os"
+	"context"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/exercism/rikki/analysis"
 	"github.com/exercism/rikki/analysis/crystal"
 	"github.com/exercism/rikki/analysis/golang"
 	"github.com/exercism/rikki/analysis/ruby"
 	"github.com/jrallison/go-workers"
+	"github.com/sirupsen/logrus"
 )
 
 // Analyzer is a job that provides feedback on specific issues in the code.
@@ -19,43 +21,75 @@ import (
 // back to the conversation on exercism.
 type Analyzer struct {
 	exercism *Exercism
-	comments map[string]map[string][]byte
+	comments library
+	footer   *footer
+	limiter  *trackLimiter
+	votes    *votes
+	audit    *auditLog
+	cache    *analysisCache
+	limits   *commentLimiter
+	mentor   *mentorOnly
 }
 
 type analyzeFunc func(string, map[string]string) ([]string, error)
 
+// analyzers detect smells in the code of each track rikki- supports.
+var analyzers = map[string]analyzeFunc{
+	"ruby":    ruby.Analyze,
+	"go":      golang.Analyze,
+	"crystal": crystal.Analyze,
+}
+
+// catalogs describe the smells each track's analyzer can report.
+var catalogs = map[string]analysis.Catalog{
+	"ruby":    ruby.Catalog,
+	"go":      golang.Catalog,
+	"crystal": crystal.Catalog,
+}
+
+// versions identify each track's analyzer, so that a new version
+// doesn't reuse cached results.
+var versions = map[string]string{
+	"ruby":    ruby.Version,
+	"go":      golang.Version,
+	"crystal": crystal.Version,
+}
+
+// shuffled are the tracks whose analyzer reports smells in random order,
+// so that students with several smells get varied feedback.
+var shuffled = map[string]bool{
+	"ruby": true,
+}
+
+// review is what rikki- makes of a solution: the smells it detected,
+// the comment it chose to post about them, if any, and the findings that
+// are for mentors only.
+type review struct {
+	smells  []string
+	smell   string
+	variant string
+	locale  string
+	comment []byte
+	notes   []string
+}
+
 // NewAnalyzer configures an analyzer job to talk to the exercism and whatever analysis APIs we're using.
 // We load the comments from disc when we create the analyzer.
 // This means that rikki- has to be restarted if we update the comments.
 func NewAnalyzer(exercism *Exercism, dir string) (*Analyzer, error) {
-	dir = filepath.Join(dir, "analyzer")
-
-	comments := make(map[string]map[string][]byte)
-
-	fn := func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
-		}
-		b, err := read(path)
-		if err != nil {
-			return err
-		}
-		trackID, smell := identifyComment(dir, path)
-		if comments[trackID] == nil {
-			comments[trackID] = make(map[string][]byte)
-		}
-		comments[trackID][smell] = b
-
-		return nil
+	comments, err := loadComments(filepath.Join(dir, "analyzer"))
+	if err != nil {
+		return nil, err
 	}
-
-	if err := filepath.Walk(dir, fn); err != nil {
+	footer, err := loadFooter(dir)
+	if err != nil {
 		return nil, err
 	}
 
 	return &Analyzer{
 		exercism: exercism,
 		comments: comments,
+		footer:   footer,
 	}, nil
 }
 
@@ -73,59 +107,225 @@ func identifyComment(dir, path string) (trackID, smell string) {
 }
 
 func (analyzer *Analyzer) process(msg *workers.Msg) {
+	job := &outcome{queue: queueOf(msg), result: "error"}
+	defer job.record()
+	log := jobLogger(msg)
+
 	// Fetch the solution from the Exercism API.
 	uuid, err := msg.Args().GetIndex(0).String()
 	if err != nil {
-		lgr.Printf("unable to determine submission key - %s\n", err)
+		log.WithError(err).Error("unable to determine submission key")
+		return
+	}
+	log = log.WithField("uuid", uuid)
+
+	// Keep a record of what we did, however it turns out.
+	entry := &auditEntry{UUID: uuid, Started: time.Now()}
+	defer func() {
+		entry.Result = job.result
+		entry.Total = time.Since(entry.Started)
+		analyzer.audit.record(entry, log)
+	}()
+
+	// Holding the job back before it's fetched only makes sense when all
+	// it could post is a comment for the student. Notes for mentors don't
+	// count towards the limits.
+	if analyzer.mentor == nil && !analyzer.limits.wait(msg, job, log) {
 		return
 	}
-	solution, err := analyzer.exercism.FetchSolution(uuid)
+
+	ctx := context.Background()
+	solution, err := analyzer.exercism.FetchSolution(ctx, uuid)
 	if err != nil {
-		lgr.Printf("%s\n", err)
+		log.WithError(err).Error("unable to fetch solution")
+		entry.fail(err)
+		job.retry(msg, err)
 		return
 	}
+	job.track = solution.TrackID
+	log = log.WithFields(logrus.Fields{"track": solution.TrackID, "slug": solution.Slug})
+	entry.Track, entry.Slug, entry.Files = solution.TrackID, solution.Slug, hashFiles(solution.Files)
 
 	// Detect known smells.
-	var fn analyzeFunc
-	switch solution.TrackID {
-	case "ruby":
-		fn = ruby.Analyze
-	case "go":
-		fn = golang.Analyze
-	case "crystal":
-		fn = crystal.Analyze
-	default:
-		lgr.Printf("skipping - rikki- doesn't support %s\n", solution.TrackID)
+	fn, ok := analyzers[solution.TrackID]
+	if !ok {
+		log.Info("skipping - rikki- doesn't support this track")
+		job.result = "skipped"
 		return
 	}
-	smells, err := fn(solution.Slug, solution.Files)
+
+	start := time.Now()
+	rev, cached, err := analyzer.run(fn, solution)
+	entry.Cached = cached
+	if err == errTrackBusy {
+		// Leave the job for later.
+		if err := requeue(msg, requeueDelay); err != nil {
+			log.WithError(err).Error("unable to defer job")
+			return
+		}
+		log.Info("deferred - track is at its concurrency limit")
+		job.result = "deferred"
+		return
+	}
+	entry.Analysis = time.Since(start)
+	if !cached {
+		// Cache hits would drag the latency of real analyses towards zero.
+		analysisDuration.WithLabelValues(solution.TrackID).Observe(entry.Analysis.Seconds())
+	}
 	if err != nil {
-		lgr.Printf("%s - %s", uuid, err)
+		log.WithError(err).Error("analysis failed")
+		entry.fail(err)
 		return
 	}
+	entry.Analyzed, entry.Smells = true, rev.smells
 
 	// Log what we found.
-	sanity := log.New(os.Stdout, "SANITY: ", log.Ldate|log.Ltime|log.Lshortfile)
-	for _, smell := range smells {
-		sanity.Printf("%s : %s\n", uuid, smell)
+	for _, smell := range rev.smells {
+		log.WithField("smell", smell).Info("smell detected")
+		smellsDetected.WithLabelValues(solution.TrackID, smell).Inc()
 	}
 
-	// Select the first smell that we have a comment for.
-	var comment []byte
-	for _, smell := range smells {
-		b := analyzer.comments[solution.TrackID][smell]
+	if len(rev.comment) == 0 && len(rev.notes) == 0 {
+		log.Debug("no comment for any detected smell")
+		job.result = "no_comment"
+		return
+	}
+	entry.Notes = rev.notes
 
-		if len(b) > 0 {
-			comment = b
-			break
-		}
+	// Notes for mentors go first, since they don't count towards the
+	// comment limits, and so aren't held back with the student's comment.
+	if len(rev.notes) > 0 && !analyzer.note(ctx, msg, job, log, entry, solution, rev) {
+		return
 	}
-	if len(comment) == 0 {
+	if len(rev.comment) == 0 {
+		job.result = "noted"
 		return
 	}
 
 	// Submit the comment back to the Exercism API.
-	if err := analyzer.exercism.SubmitComment(comment, uuid); err != nil {
-		lgr.Printf("%s\n", err)
+	log = log.WithFields(logrus.Fields{"comment": rev.smell, "variant": rev.variant, "locale": rev.locale})
+	entry.Comment, entry.Variant, entry.Locale = rev.smell, rev.variant, rev.locale
+	if !analyzer.limits.admit(msg, job, log, solution.Username, solution.TrackID) {
+		return
+	}
+	analyzer.comment(ctx, msg, job, log, entry, solution, rev)
+}
+
+// notesLeft marks a job whose notes for mentors have been left, so that
+// they aren't left again if the job is put back on the queue, or retried,
+// for the sake of the student's comment.
+const notesLeft = "notes-left"
+
+// note leaves the findings that are for mentors only in a private note,
+// unless an earlier run of the job already did, and reports whether the
+// job can go on. The footer is for students, so notes go without it.
+func (analyzer *Analyzer) note(ctx context.Context, msg *workers.Msg, job *outcome, log *logrus.Entry, entry *auditEntry, solution *Solution, rev *review) bool {
+	log = log.WithField("notes", rev.notes)
+	args := msg.Args()
+	if s, _ := args.GetIndex(1).String(); s == notesLeft {
+		log.Debug("note already submitted")
+		return true
+	}
+	if err := analyzer.exercism.SubmitNote(ctx, mentorNote(solution.TrackID, rev.notes), solution.UUID); err != nil {
+		log.WithError(err).Error("unable to submit note")
+		entry.fail(err)
+		job.retry(msg, err)
+		return false
+	}
+	log.Info("note submitted")
+	for _, smell := range rev.notes {
+		notesPosted.WithLabelValues(solution.TrackID, smell).Inc()
+	}
+	msg.Set("args", []interface{}{solution.UUID, notesLeft})
+	return true
+}
+
+// comment signs the comment for the student and posts it.
+func (analyzer *Analyzer) comment(ctx context.Context, msg *workers.Msg, job *outcome, log *logrus.Entry, entry *auditEntry, solution *Solution, rev *review) {
+	uuid := solution.UUID
+	data := footerData{
+		Track:   solution.TrackID,
+		Smell:   rev.smell,
+		Variant: rev.variant,
+		UUID:    uuid,
+	}
+	data.HelpfulURL, data.UnhelpfulURL = analyzer.votes.links(data)
+	comment, err := analyzer.footer.sign(rev.comment, data)
+	if err != nil {
+		log.WithError(err).Error("unable to sign comment")
+		entry.fail(err)
+		return
+	}
+	if err := analyzer.exercism.SubmitComment(ctx, comment, uuid); err != nil {
+		log.WithError(err).Error("unable to submit comment")
+		entry.fail(err)
+		job.retry(msg, err)
+		return
+	}
+	log.Info("comment submitted")
+	commentsPosted.WithLabelValues(solution.TrackID, rev.smell, rev.variant).Inc()
+	job.result = "commented"
+}
+
+// run analyzes a solution through the cache, in one of the track's slots,
+// and chooses a comment about what it finds.
+func (analyzer *Analyzer) run(fn analyzeFunc, solution *Solution) (*review, bool, error) {
+	smells, cached, err := detect(analyzer.cache, analyzer.limiter, fn, solution)
+	if err != nil {
+		return nil, cached, err
+	}
+	return analyzer.review(smells, solution), cached, nil
+}
+
+// detect runs fn over a solution through the cache, in one of the track's
+// slots. Code we've seen before doesn't need analyzing again, so it doesn't
+// take a slot. If the track is already as busy as we allow, detect returns
+// errTrackBusy without analyzing anything.
+func detect(cache *analysisCache, limiter *trackLimiter, fn analyzeFunc, solution *Solution) (smells []string, cached bool, err error) {
+	cached = cache.contains(solution)
+	if !cached {
+		if !limiter.acquire(solution.TrackID) {
+			return nil, false, errTrackBusy
+		}
+		defer limiter.release(solution.TrackID)
+	}
+	smells, err = cache.wrap(solution, fn)(solution.Slug, solution.Files)
+	return smells, cached, err
+}
+
+// analyze detects smells in a solution, and chooses a comment about them.
+// It doesn't post anything, so it is safe to use for a dry run.
+func (analyzer *Analyzer) analyze(fn analyzeFunc, solution *Solution) (*review, error) {
+	smells, err := fn(solution.Slug, solution.Files)
+	if err != nil {
+		return nil, err
+	}
+	return analyzer.review(smells, solution), nil
+}
+
+// review chooses a comment about the smells detected in a solution, and sets
+// aside the findings that are for mentors only.
+func (analyzer *Analyzer) review(smells []string, solution *Solution) *review {
+	rev := &review{smells: smells}
+
+	// Select the first public smell that we have a comment for,
+	// in the student's language if it has been translated.
+	for _, smell := range smells {
+		if analyzer.mentor.private(solution.TrackID, smell) {
+			rev.notes = append(rev.notes, smell)
+			continue
+		}
+		if rev.comment != nil {
+			continue
+		}
+		b, variant, locale := analyzer.comments.comment(solution.TrackID, smell, solution.UUID, solution.Locale)
+
+		if len(b) > 0 {
+			rev.smell = smell
+			rev.variant = variant
+			rev.locale = locale
+			rev.comment = b
+		}
 	}
+	return rev
 }
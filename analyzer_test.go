@@ -1,6 +1,15 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/exercism/rikki/exercismtest"
+	"github.com/jrallison/go-workers"
+)
 
 func TestIdentifyComment(t *testing.T) {
 	tests := []struct {
@@ -21,3 +30,134 @@ func TestIdentifyComment(t *testing.T) {
 		}
 	}
 }
+
+func newTestMsg(t *testing.T, queue string, args ...interface{}) *workers.Msg {
+	b, err := json.Marshal(map[string]interface{}{
+		"jid":   "jid-1",
+		"queue": queue,
+		"class": "Jobs::Test",
+		"args":  args,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := workers.NewMsg(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// stubbedCode is a go/leap solution with a single smell: stub.
+const stubbedCode = "// Package leap is a stub.\npackage leap\n"
+
+// newTestAnalyzer sets up an analyzer to talk to a fake exercism API, which
+// serves a "stubbed" go/leap submission. Tests add any other submissions
+// they need, and close the API when they're done.
+func newTestAnalyzer(t *testing.T) (*Analyzer, *exercismtest.Server) {
+	api := exercismtest.NewServer()
+	api.AddSubmission("stubbed", exercismtest.Submission{
+		TrackID: "go",
+		Slug:    "leap",
+		Files:   map[string]string{"leap.go": stubbedCode},
+	})
+
+	analyzer, err := NewAnalyzer(NewExercism(api.URL, SharedKey{Key: "key"}, nil), "comments")
+	if err != nil {
+		api.Close()
+		t.Fatal(err)
+	}
+	return analyzer, api
+}
+
+func TestAnalyzerProcess(t *testing.T) {
+	stub, err := ioutil.ReadFile("comments/analyzer/go/stub.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gofmtES, err := ioutil.ReadFile("comments/analyzer/go/gofmt.es.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc    string
+		uuid    string
+		comment []byte
+	}{
+		{"comment on first smell", "stubbed", stub},
+		{"comment in the student's language", "spanish", gofmtES},
+		{"fall back to english", "spanish-stubbed", stub},
+		{"unsupported track", "haskell", nil},
+		{"unknown submission", "missing", nil},
+	}
+
+	for _, test := range tests {
+		analyzer, api := newTestAnalyzer(t)
+		api.AddSubmission("spanish", exercismtest.Submission{
+			TrackID: "go",
+			Slug:    "leap",
+			Files:   map[string]string{"leap.go": "// Package leap tells leap years apart.\npackage leap\n\n// Year is a year.\ntype Year  int\n"},
+			Locale:  "es",
+		})
+		api.AddSubmission("spanish-stubbed", exercismtest.Submission{
+			TrackID: "go",
+			Slug:    "leap",
+			Files:   map[string]string{"leap.go": stubbedCode},
+			Locale:  "es",
+		})
+		api.AddSubmission("haskell", exercismtest.Submission{TrackID: "haskell", Slug: "leap"})
+
+		analyzer.process(newTestMsg(t, "analyze", test.uuid))
+		comments := api.Comments()
+		api.Close()
+
+		if test.comment == nil {
+			if len(comments) > 0 {
+				t.Errorf("%s: expected no comment, got %q", test.desc, comments[0].Body)
+			}
+			continue
+		}
+		if len(comments) != 1 {
+			t.Errorf("%s: got %d comments, want 1", test.desc, len(comments))
+			continue
+		}
+		if !bytes.HasPrefix([]byte(comments[0].Body), test.comment) {
+			t.Errorf("%s: got comment %q, want %q", test.desc, comments[0].Body, test.comment)
+		}
+	}
+}
+
+func TestAnalyzerRetriesServerErrors(t *testing.T) {
+	analyzer, api := newTestAnalyzer(t)
+	defer api.Close()
+	api.Fail(exercismtest.Failure{Status: http.StatusServiceUnavailable})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic, so that the job is retried")
+		}
+	}()
+	analyzer.process(newTestMsg(t, "analyze", "stubbed"))
+}
+
+func TestAnalyzerRunReleasesSlotOnPanic(t *testing.T) {
+	analyzer := &Analyzer{limiter: newTrackLimiter(map[string]TrackConfig{"ruby": {Concurrency: 1}})}
+	solution := &Solution{TrackID: "ruby", Slug: "leap"}
+	panics := func(string, map[string]string) ([]string, error) { panic("analyzer blew up") }
+
+	func() {
+		defer func() { recover() }()
+		analyzer.run(panics, solution)
+	}()
+
+	if _, _, err := analyzer.run(func(string, map[string]string) ([]string, error) { return nil, nil }, solution); err != nil {
+		t.Errorf("the slot should have been released, got: %s", err)
+	}
+	if !analyzer.limiter.acquire("ruby") {
+		t.Error("the slot should have been released after the analysis")
+	}
+	if _, _, err := analyzer.run(panics, solution); err != errTrackBusy {
+		t.Errorf("got: %v, want: %s", err, errTrackBusy)
+	}
+}
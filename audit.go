@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// auditBucket holds an entry per analysis job, keyed by the time it started.
+const auditBucket = "analyses"
+
+// auditTime formats the time in keys. It is fixed width, so that keys sort in time order.
+const auditTime = "2006-01-02T15:04:05.000000000Z"
+
+// auditEntry records what an analysis job did, for reviewing rikki-'s behavior later.
+type auditEntry struct {
+	UUID     string            `json:"uuid"`
+	Track    string            `json:"track,omitempty"`
+	Slug     string            `json:"slug,omitempty"`
+	Files    map[string]string `json:"files,omitempty"`
+	Analyzed bool              `json:"analyzed,omitempty"`
+	Smells   []string          `json:"smells,omitempty"`
+	Cached   bool              `json:"cached,omitempty"`
+	Comment  string            `json:"comment,omitempty"`
+	Notes    []string          `json:"notes,omitempty"`
+	Variant  string            `json:"variant,omitempty"`
+	Locale   string            `json:"locale,omitempty"`
+	Result   string            `json:"result"`
+	Error    string            `json:"error,omitempty"`
+	Started  time.Time         `json:"started"`
+	Analysis time.Duration     `json:"analysis_ns"`
+	Total    time.Duration     `json:"total_ns"`
+}
+
+// posted is the smell that the student actually got a comment about.
+// A comment that was chosen but then held back, or failed to post, doesn't
+// count, and neither do findings left for mentors.
+func (e *auditEntry) posted() string {
+	if e.Result == "commented" {
+		return e.Comment
+	}
+	return ""
+}
+
+// fail notes why the job failed.
+func (e *auditEntry) fail(err error) {
+	e.Error = err.Error()
+}
+
+// hashFiles identifies the contents of each file, without keeping the code.
+func hashFiles(files map[string]string) map[string]string {
+	hashes := make(map[string]string, len(files))
+	for name, source := range files {
+		sum := sha256.Sum256([]byte(source))
+		hashes[name] = hex.EncodeToString(sum[:])
+	}
+	return hashes
+}
+
+// auditLog keeps an entry for every analysis in the store.
+// A nil auditLog doesn't keep anything.
+type auditLog struct {
+	store *store
+}
+
+// record stores an entry. Failing to do so doesn't fail the job, so it is only logged.
+func (a *auditLog) record(e *auditEntry, log *logrus.Entry) {
+	if a == nil {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.WithError(err).Error("unable to record analysis")
+		return
+	}
+	key := e.Started.UTC().Format(auditTime) + " " + e.UUID
+	err = a.store.update(auditBucket, func(bucket *bolt.Bucket) error {
+		return bucket.Put([]byte(key), b)
+	})
+	if err != nil {
+		log.WithError(err).Error("unable to record analysis")
+	}
+}
+
+// auditFilter selects entries from the audit log.
+// Empty fields match everything.
+type auditFilter struct {
+	since time.Time
+	track string
+	smell string
+	uuid  string
+}
+
+func (f auditFilter) match(e *auditEntry) bool {
+	if f.track != "" && e.Track != f.track {
+		return false
+	}
+	if f.uuid != "" && e.UUID != f.uuid {
+		return false
+	}
+	if f.smell == "" {
+		return true
+	}
+	for _, smell := range e.Smells {
+		if smell == f.smell {
+			return true
+		}
+	}
+	return false
+}
+
+// entries finds the entries that match the filter, oldest first.
+func (a *auditLog) entries(f auditFilter) ([]*auditEntry, error) {
+	var entries []*auditEntry
+	err := a.store.view(auditBucket, func(bucket *bolt.Bucket) error {
+		c := bucket.Cursor()
+		// Keys start with the time, so they're in order and we can skip
+		// straight to the first entry of interest.
+		k, v := c.First()
+		if !f.since.IsZero() {
+			k, v = c.Seek([]byte(f.since.UTC().Format(auditTime)))
+		}
+		for ; k != nil; k, v = c.Next() {
+			var e auditEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("entry %s - %s", k, err)
+			}
+			if f.match(&e) {
+				entries = append(entries, &e)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// parseSince reads how far back to look: a duration such as 12h, a number of
+// days such as 7d, or a date such as 2017-06-01.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot tell how long ago %q is; try 12h, 7d or 2017-06-01", s)
+}
+
+// printEntries writes the entries as a table.
+func printEntries(w io.Writer, entries []*auditEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tUUID\tTRACK\tSLUG\tSMELLS\tCOMMENT\tRESULT\tDURATION")
+	for _, e := range entries {
+		dash := func(s string) string {
+			if s == "" {
+				return "-"
+			}
+			return s
+		}
+		comment := e.Comment
+		if e.Variant != "" {
+			comment += "/" + e.Variant
+		}
+		result := e.Result
+		if e.Error != "" {
+			result += " (" + e.Error + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Started.UTC().Format(time.RFC3339), e.UUID, dash(e.Track), dash(e.Slug),
+			dash(strings.Join(e.Smells, ",")), dash(comment), result, e.Total.Round(time.Millisecond))
+	}
+	return tw.Flush()
+}
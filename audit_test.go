@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2017, 6, 8, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s    string
+		want time.Time
+	}{
+		{"7d", time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"12h", time.Date(2017, 6, 8, 0, 0, 0, 0, time.UTC)},
+		{"90m", time.Date(2017, 6, 8, 10, 30, 0, 0, time.UTC)},
+		{"2017-05-01", time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		got, err := parseSince(test.s, now)
+		if err != nil {
+			t.Errorf("%s: %s", test.s, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%s - got: %s, want: %s", test.s, got, test.want)
+		}
+	}
+
+	for _, s := range []string{"", "d", "-7d", "last week"} {
+		if _, err := parseSince(s, now); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rikki-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := newTestStore(t, filepath.Join(dir, "rikki.db"))
+	defer store.Close()
+	audit := &auditLog{store: store}
+
+	// Nothing has been recorded yet.
+	if entries, err := audit.entries(auditFilter{}); err != nil || len(entries) > 0 {
+		t.Fatalf("got: %v, %v, want no entries", entries, err)
+	}
+
+	now := time.Now()
+	log := lgr.WithField("test", "audit")
+	audit.record(&auditEntry{UUID: "old", Track: "go", Smells: []string{"gofmt"}, Result: "commented", Started: now.AddDate(0, 0, -10)}, log)
+	audit.record(&auditEntry{UUID: "a", Track: "go", Smells: []string{"gofmt", "stub"}, Result: "commented", Started: now.Add(-2 * time.Hour)}, log)
+	audit.record(&auditEntry{UUID: "b", Track: "ruby", Smells: []string{"for_loop/for_loop"}, Result: "commented", Started: now.Add(-time.Hour)}, log)
+	audit.record(&auditEntry{UUID: "c", Track: "go", Result: "no_comment", Started: now.Add(-time.Minute)}, log)
+
+	tests := []struct {
+		desc   string
+		filter auditFilter
+		uuids  []string
+	}{
+		{"everything", auditFilter{}, []string{"old", "a", "b", "c"}},
+		{"since", auditFilter{since: now.AddDate(0, 0, -7)}, []string{"a", "b", "c"}},
+		{"smell", auditFilter{smell: "gofmt"}, []string{"old", "a"}},
+		{"smell since", auditFilter{smell: "gofmt", since: now.AddDate(0, 0, -7)}, []string{"a"}},
+		{"track", auditFilter{track: "go"}, []string{"old", "a", "c"}},
+		{"uuid", auditFilter{uuid: "b"}, []string{"b"}},
+	}
+	for _, test := range tests {
+		entries, err := audit.entries(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var uuids []string
+		for _, e := range entries {
+			uuids = append(uuids, e.UUID)
+		}
+		if !reflect.DeepEqual(uuids, test.uuids) {
+			t.Errorf("%s - got: %s, want: %s", test.desc, uuids, test.uuids)
+		}
+	}
+}
+
+func TestAnalyzerAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rikki-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	analyzer, api := newTestAnalyzer(t)
+	defer api.Close()
+	store := newTestStore(t, filepath.Join(dir, "rikki.db"))
+	defer store.Close()
+	analyzer.audit = &auditLog{store: store}
+	analyzer.process(newTestMsg(t, "analyze", "stubbed"))
+	analyzer.process(newTestMsg(t, "analyze", "missing"))
+
+	entries, err := analyzer.audit.entries(auditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	e := entries[0]
+	if e.UUID != "stubbed" || e.Track != "go" || e.Slug != "leap" || e.Comment != "stub" || e.Result != "commented" {
+		t.Errorf("unexpected entry %#v", e)
+	}
+	if !reflect.DeepEqual(e.Smells, []string{"stub"}) {
+		t.Errorf("smells - got: %s, want: [stub]", e.Smells)
+	}
+	if len(e.Files["leap.go"]) != 64 {
+		t.Errorf("expected a sha256 of leap.go, got %q", e.Files["leap.go"])
+	}
+	if e.Total <= 0 {
+		t.Error("expected the job to be timed")
+	}
+
+	e = entries[1]
+	if e.UUID != "missing" || e.Result != "error" || !strings.Contains(e.Error, "404") {
+		t.Errorf("unexpected entry %#v", e)
+	}
+
+	var buf bytes.Buffer
+	if err := printEntries(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "stubbed  go     leap") {
+		t.Errorf("unexpected history:\n%s", buf.String())
+	}
+}
@@ -1,36 +1,106 @@
 package main
 
 import (
+	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
-	"os"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
 )
 
-// Auth is a simple shared secret.
-// If the rikki project ever becomes a larger part of the
-// exercism ecosystem, we should consider improving the security
-// around it.
+// hmacScheme is the Authorization scheme of a signed request.
+const hmacScheme = "Rikki-HMAC-SHA256"
+
+// timestampHeader carries the time a request was signed, in unix seconds.
+const timestampHeader = "X-Rikki-Timestamp"
+
+// Signer authenticates requests to the exercism API.
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// Auth is a shared secret, used to sign requests to the exercism API.
+//
+// Requests are signed with Secret. The Retired secrets are never used to
+// sign anything, but tokens they made, such as those in vote links that
+// were posted before the secret was rotated, are still accepted.
 type Auth struct {
-	Secret string
+	Secret  string
+	Retired []string
 }
 
+// devSecret is the pass phrase used in development when no secret is configured.
+// It is public, so rikki- refuses to run with it in production.
+const devSecret = "I wish a robot would get elected president. That way, when he came to town, we could all take a shot at him and not feel too bad."
+
 // NewAuth configures a shared secret.
-// It looks in an environment variable for a pass phrase, and
-// falls back to a common value for development if not set.
-func NewAuth() *Auth {
-	a := Auth{}
-	a.Secret = os.Getenv("RIKKI_SECRET")
+// It falls back to a common value for development if the
+// pass phrase is empty.
+func NewAuth(secret string, retired []string) *Auth {
+	a := Auth{Secret: secret, Retired: retired}
 	if a.Secret == "" {
 		// Use a default value in development mode.
-		a.Secret = "I wish a robot would get elected president. That way, when he came to town, we could all take a shot at him and not feel too bad."
+		a.Secret = devSecret
 	}
 	return &a
 }
 
-// Key returns the SHA1 hexdigest of the shared secret
-// kind of stupid, since there's no salt
+// Key returns the SHA1 hexdigest of the shared secret.
+// This is kind of stupid, since there's no salt, so it's only used
+// by the SharedKey signer for servers that don't verify signed requests yet.
 func (a *Auth) Key() string {
 	hasher := sha1.New()
 	hasher.Write([]byte(a.Secret))
 	return hex.EncodeToString(hasher.Sum(nil))
 }
+
+// Sign signs a request with the current secret.
+//
+// The signature is the hex-encoded HMAC-SHA256 of the method, the request URI,
+// the timestamp and the hex-encoded SHA256 of the body, separated by newlines.
+// It is sent along with the ID of the secret that made it:
+//
+//	Authorization: Rikki-HMAC-SHA256 <key id>:<signature>
+//	X-Rikki-Timestamp: <unix seconds>
+func (a *Auth) Sign(req *http.Request, body []byte) error {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sig := signature(a.Secret, req.Method, req.URL.RequestURI(), ts, body)
+	req.Header.Set(timestampHeader, ts)
+	req.Header.Set("Authorization", fmt.Sprintf("%s %s:%s", hmacScheme, keyID(a.Secret), sig))
+	return nil
+}
+
+// keyID identifies a secret without giving it away.
+func keyID(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+func signature(secret, method, uri, ts string, body []byte) string {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, uri, ts, hex.EncodeToString(digest[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SharedKey authenticates with the SHA1 of the secret, for servers that
+// don't verify signed requests yet.
+// With Legacy set, the key is also sent as the shared_key query parameter.
+type SharedKey struct {
+	Key    string
+	Legacy bool
+}
+
+// Sign adds the shared key to the request.
+func (k SharedKey) Sign(req *http.Request, body []byte) error {
+	req.Header.Set("Authorization", "Token "+k.Key)
+	if k.Legacy {
+		q := req.URL.Query()
+		q.Set("shared_key", k.Key)
+		req.URL.RawQuery = q.Encode()
+	}
+	return nil
+}
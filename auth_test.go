@@ -1,6 +1,14 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
 
 func TestAuthKey(t *testing.T) {
 	auth := Auth{Secret: "Ceci n'est pas un string"}
@@ -10,3 +18,56 @@ func TestAuthKey(t *testing.T) {
 		t.Fatalf("Expected: %s, Got: %s", hash, key)
 	}
 }
+
+func signedRequest(t *testing.T, a *Auth, body string) *http.Request {
+	req, err := http.NewRequest("POST", "http://exercism.io/api/v1/submissions/abc/comments", bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Sign(req, []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// verifySignature checks a request the way the exercism API does.
+func verifySignature(secret string, req *http.Request, body []byte) error {
+	ts := req.Header.Get(timestampHeader)
+	want := fmt.Sprintf("%s %s:%s", hmacScheme, keyID(secret), signature(secret, req.Method, req.URL.RequestURI(), ts, body))
+	if req.Header.Get("Authorization") != want {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func TestAuthSign(t *testing.T) {
+	a := &Auth{Secret: "s3cret"}
+
+	req := signedRequest(t, a, "hi")
+	if err := verifySignature("s3cret", req, []byte("hi")); err != nil {
+		t.Error(err)
+	}
+	if err := verifySignature("other", req, []byte("hi")); err == nil {
+		t.Error("signature should depend on the secret")
+	}
+	if err := verifySignature("s3cret", req, []byte("bye")); err == nil {
+		t.Error("signature should cover the body")
+	}
+	req.Method = "DELETE"
+	if err := verifySignature("s3cret", req, []byte("hi")); err == nil {
+		t.Error("signature should cover the method")
+	}
+
+	secs, err := strconv.ParseInt(req.Header.Get(timestampHeader), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skew := time.Since(time.Unix(secs, 0)); skew > time.Minute {
+		t.Errorf("timestamp is %s old", skew)
+	}
+
+	retired := &Auth{Secret: "new", Retired: []string{"old"}}
+	if err := verifySignature("new", signedRequest(t, retired, "hi"), []byte("hi")); err != nil {
+		t.Errorf("requests should be signed with the current secret: %s", err)
+	}
+}
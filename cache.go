@@ -0,0 +1,149 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// analysisCache remembers the smells detected in code we've seen before.
+// Early exercises get a lot of identical solutions, and there's no need to
+// run gofmt, vet and lint, or call a remote analyzer, for each of them.
+//
+// Entries are keyed by the content of the solution and the version of the
+// analyzer. The least recently used entries are evicted once the cache is
+// full, and entries expire after the TTL.
+// A nil analysisCache doesn't cache anything.
+type analysisCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	lru   *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+type cacheItem struct {
+	key     string
+	smells  []string
+	expires time.Time
+}
+
+// newAnalysisCache makes a cache of up to size entries.
+// A size of zero disables caching.
+func newAnalysisCache(size int, ttl time.Duration) *analysisCache {
+	if size <= 0 {
+		return nil
+	}
+	return &analysisCache{
+		size:  size,
+		ttl:   ttl,
+		lru:   list.New(),
+		items: map[string]*list.Element{},
+		now:   time.Now,
+	}
+}
+
+// cacheKey hashes everything that decides what an analysis finds: the track,
+// the exercise, the version of the analyzer, and the files.
+func cacheKey(solution *Solution) string {
+	names := make([]string, 0, len(solution.Files))
+	for name := range solution.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, s := range []string{solution.TrackID, solution.Slug, versions[solution.TrackID]} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(solution.Files[name]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *analysisCache) get(key string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*cacheItem)
+	if c.ttl > 0 && c.now().After(item.expires) {
+		c.lru.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return append([]string(nil), item.smells...), true
+}
+
+func (c *analysisCache) put(key string, smells []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := &cacheItem{key: key, smells: append([]string(nil), smells...), expires: c.now().Add(c.ttl)}
+	if el, ok := c.items[key]; ok {
+		el.Value = item
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.lru.PushFront(item)
+	for c.lru.Len() > c.size {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.items, el.Value.(*cacheItem).key)
+	}
+}
+
+// contains reports whether the smells in the solution are already known.
+func (c *analysisCache) contains(solution *Solution) bool {
+	_, ok := c.get(cacheKey(solution))
+	return ok
+}
+
+// wrap puts the cache in front of the analyzer for a solution.
+// Failed analyses aren't cached.
+//
+// Smells are cached in the order the analyzer reported them, which is the
+// order comments are chosen in. For tracks whose analyzer shuffles them,
+// each hit is shuffled again, so that a cached analysis doesn't freeze one
+// order for everyone who submits the same code.
+func (c *analysisCache) wrap(solution *Solution, fn analyzeFunc) analyzeFunc {
+	if c == nil {
+		return fn
+	}
+	key := cacheKey(solution)
+	return func(slug string, files map[string]string) ([]string, error) {
+		if smells, ok := c.get(key); ok {
+			cacheLookups.WithLabelValues(solution.TrackID, "hit").Inc()
+			if shuffled[solution.TrackID] {
+				rand.Shuffle(len(smells), func(i, j int) { smells[i], smells[j] = smells[j], smells[i] })
+			}
+			return smells, nil
+		}
+		cacheLookups.WithLabelValues(solution.TrackID, "miss").Inc()
+		smells, err := fn(slug, files)
+		if err == nil {
+			c.put(key, smells)
+		}
+		return smells, err
+	}
+}
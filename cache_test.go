@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	base := &Solution{TrackID: "go", Slug: "leap", Files: map[string]string{"leap.go": "package leap"}}
+	key := cacheKey(base)
+
+	tests := []struct {
+		desc     string
+		solution *Solution
+		same     bool
+	}{
+		{"same code", &Solution{UUID: "other", TrackID: "go", Slug: "leap", Files: map[string]string{"leap.go": "package leap"}}, true},
+		{"different code", &Solution{TrackID: "go", Slug: "leap", Files: map[string]string{"leap.go": "package leap\n"}}, false},
+		{"different file name", &Solution{TrackID: "go", Slug: "leap", Files: map[string]string{"year.go": "package leap"}}, false},
+		{"different exercise", &Solution{TrackID: "go", Slug: "clock", Files: map[string]string{"leap.go": "package leap"}}, false},
+		{"different track", &Solution{TrackID: "ruby", Slug: "leap", Files: map[string]string{"leap.go": "package leap"}}, false},
+	}
+
+	for _, test := range tests {
+		if same := cacheKey(test.solution) == key; same != test.same {
+			t.Errorf("%s - got same key: %t, want: %t", test.desc, same, test.same)
+		}
+	}
+
+	version := versions["go"]
+	defer func() { versions["go"] = version }()
+	versions["go"] = version + "-next"
+	if cacheKey(base) == key {
+		t.Error("a new analyzer version should change the key")
+	}
+}
+
+func TestAnalysisCache(t *testing.T) {
+	now := time.Date(2016, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := newAnalysisCache(2, time.Hour)
+	c.now = func() time.Time { return now }
+
+	c.put("a", []string{"gofmt"})
+	c.put("b", nil)
+	if smells, ok := c.get("a"); !ok || !reflect.DeepEqual(smells, []string{"gofmt"}) {
+		t.Errorf("got: %q %t, want: [gofmt] true", smells, ok)
+	}
+
+	// b is now the least recently used.
+	c.put("c", []string{"stub"})
+	if _, ok := c.get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("a should still be cached")
+	}
+
+	now = now.Add(time.Hour + time.Second)
+	if _, ok := c.get("c"); ok {
+		t.Error("c should have expired")
+	}
+}
+
+func TestAnalysisCacheWrap(t *testing.T) {
+	c := newAnalysisCache(10, time.Hour)
+	solution := &Solution{TrackID: "go", Slug: "leap", Files: map[string]string{"leap.go": "package leap"}}
+
+	calls := 0
+	fail := true
+	fn := func(slug string, files map[string]string) ([]string, error) {
+		calls++
+		if fail {
+			return nil, errors.New("analyzer is down")
+		}
+		return []string{"gofmt"}, nil
+	}
+
+	if _, err := c.wrap(solution, fn)(solution.Slug, solution.Files); err == nil {
+		t.Fatal("expected the error to come through")
+	}
+	if c.contains(solution) {
+		t.Error("failed analyses should not be cached")
+	}
+
+	fail = false
+	for i := 0; i < 3; i++ {
+		smells, err := c.wrap(solution, fn)(solution.Slug, solution.Files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(smells, []string{"gofmt"}) {
+			t.Errorf("got: %q, want: [gofmt]", smells)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("analyzer called %d times, want: 2", calls)
+	}
+}
+
+func TestAnalysisCacheDisabled(t *testing.T) {
+	c := newAnalysisCache(0, time.Hour)
+	solution := &Solution{TrackID: "go", Slug: "leap"}
+	calls := 0
+	fn := func(slug string, files map[string]string) ([]string, error) {
+		calls++
+		return nil, nil
+	}
+	c.wrap(solution, fn)(solution.Slug, solution.Files)
+	c.wrap(solution, fn)(solution.Slug, solution.Files)
+	if calls != 2 || c.contains(solution) {
+		t.Errorf("a disabled cache should not cache anything, analyzer called %d times", calls)
+	}
+}
+
+func TestAnalysisCacheOrder(t *testing.T) {
+	c := newAnalysisCache(10, time.Hour)
+	// Analyzers other than ruby report smells in priority order.
+	smells := []string{"style/c", "style/a", "style/b"}
+	fn := func(slug string, files map[string]string) ([]string, error) {
+		return append([]string(nil), smells...), nil
+	}
+
+	tests := []struct {
+		track    string
+		shuffled bool
+	}{
+		{"go", false},
+		{"ruby", true},
+	}
+
+	for _, test := range tests {
+		solution := &Solution{TrackID: test.track, Slug: "leap", Files: map[string]string{"leap": "code"}}
+		first, _ := c.wrap(solution, fn)(solution.Slug, solution.Files)
+
+		orders := map[string]bool{}
+		for i := 0; i < 50; i++ {
+			got, _ := c.wrap(solution, fn)(solution.Slug, solution.Files)
+			if !test.shuffled && !reflect.DeepEqual(got, first) {
+				t.Fatalf("%s - got: %q, want the order of the analysis: %q", test.track, got, first)
+			}
+			orders[strings.Join(got, ",")] = true
+		}
+		if test.shuffled && len(orders) < 2 {
+			t.Errorf("%s - got the same order on every hit, want it shuffled", test.track)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/exercism/rikki/analysis"
+)
+
+// command is something rikki- can do other than processing jobs.
+type command struct {
+	name        string
+	usage       string
+	description string
+	fn          func(config *Config, args []string) error
+}
+
+var commands = []command{
+	{"config", "config print", "show the effective configuration, with secrets masked", configCommand},
+	{"comments", "comments lint [-online] | comments catalog", "check the comments against the smells rikki- can detect, or document those smells", commentsCommand},
+	{"history", "history [-smell key] [-track id] [-uuid uuid] [-since 7d]", "review what rikki- made of past submissions", historyCommand},
+	{"replay", "replay [-uuids file] [-smell key] [-track id] [-since 7d]", "run the current analyzers over past submissions, and show what would change", replayCommand},
+	{"votes", "votes report", "show how helpful students found each comment", votesCommand},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func configCommand(config *Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return fmt.Errorf("usage: rikki config print")
+	}
+	if err := config.print(os.Stdout); err != nil {
+		return err
+	}
+	if err := config.validate(jobs); err != nil {
+		fmt.Fprintf(os.Stderr, "\n# invalid configuration - %s\n", err)
+	}
+	return nil
+}
+
+func historyCommand(config *Config, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	smell := fs.String("smell", "", "only show analyses that detected this smell")
+	track := fs.String("track", "", "only show analyses of this track")
+	uuid := fs.String("uuid", "", "only show analyses of this submission")
+	since := fs.String("since", "", "only show analyses since then, e.g. 12h, 7d or 2017-06-01")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := auditFilter{track: *track, smell: *smell, uuid: *uuid}
+	if *since != "" {
+		t, err := parseSince(*since, time.Now())
+		if err != nil {
+			return err
+		}
+		filter.since = t
+	}
+	store, err := readStore(config.DB, config.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	audit := &auditLog{store: store}
+	entries, err := audit.entries(filter)
+	if err != nil {
+		return err
+	}
+	return printEntries(os.Stdout, entries)
+}
+
+func replayCommand(config *Config, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	file := fs.String("uuids", "", "read the uuids to replay from a file, one per line (- for stdin)")
+	smell := fs.String("smell", "", "replay past analyses that detected this smell")
+	track := fs.String("track", "", "replay past analyses of this track")
+	since := fs.String("since", "", "replay past analyses since then, e.g. 12h, 7d or 2017-06-01")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := readStore(config.DB, config.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	audit := &auditLog{store: store}
+	history, err := audit.entries(auditFilter{})
+	if err != nil {
+		return err
+	}
+	originals := latestEntries(history)
+
+	var uuids []string
+	if *file != "" {
+		r := os.Stdin
+		if *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		if uuids, err = readUUIDs(r); err != nil {
+			return err
+		}
+	} else {
+		filter := auditFilter{track: *track, smell: *smell}
+		if *since != "" {
+			if filter.since, err = parseSince(*since, time.Now()); err != nil {
+				return err
+			}
+		}
+		entries, err := audit.entries(filter)
+		if err != nil {
+			return err
+		}
+		for uuid := range latestEntries(entries) {
+			uuids = append(uuids, uuid)
+		}
+		sort.Strings(uuids)
+	}
+
+	config.configureAnalyzers()
+	analyzer, err := NewAnalyzer(config.exercism(), config.Comments)
+	if err != nil {
+		return err
+	}
+	analyzer.mentor = newMentorOnly(config.Mentor)
+	printReplay(os.Stdout, replay(context.Background(), analyzer, uuids, originals))
+	return nil
+}
+
+func votesCommand(config *Config, args []string) error {
+	if len(args) != 1 || args[0] != "report" {
+		return fmt.Errorf("usage: rikki votes report")
+	}
+	store, err := readStore(config.DB, config.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	tallies, err := tallyVotes(store)
+	if err != nil {
+		return err
+	}
+	return printTallies(os.Stdout, tallies)
+}
+
+func commentsCommand(config *Config, args []string) error {
+	usage := fmt.Errorf("usage: rikki comments lint [-online] | rikki comments catalog")
+	if len(args) < 1 {
+		return usage
+	}
+	switch args[0] {
+	case "lint":
+		return lintCommand(config, args[1:])
+	case "catalog":
+		return printCatalog(os.Stdout, catalogs)
+	}
+	return usage
+}
+
+func lintCommand(config *Config, args []string) error {
+	fs := flag.NewFlagSet("comments lint", flag.ContinueOnError)
+	online := fs.Bool("online", false, "also check that every link in a comment resolves")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var client *http.Client
+	if *online {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	problems, err := lintComments(config.Comments, catalogs, client)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems in %s", len(problems), config.Comments)
+	}
+	return nil
+}
+
+// printCatalog documents the smells of every track in markdown.
+func printCatalog(w io.Writer, catalogs map[string]analysis.Catalog) error {
+	var tracks []string
+	for track := range catalogs {
+		tracks = append(tracks, track)
+	}
+	sort.Strings(tracks)
+
+	for _, track := range tracks {
+		fmt.Fprintf(w, "## %s\n\n", track)
+		for _, key := range catalogs[track].Keys() {
+			smell, _ := catalogs[track].Lookup(key)
+			fmt.Fprintf(w, "### %s\n\n%s\n\nSeverity: %s\n\n", smell.Key, smell.Description, smell.Severity)
+			if smell.Example != "" {
+				fmt.Fprintf(w, "```\n%s\n```\n\n", smell.Example)
+			}
+		}
+	}
+	return nil
+}
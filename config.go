@@ -0,0 +1,557 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/exercism/rikki/analysis"
+	"github.com/exercism/rikki/analysis/crystal"
+	"github.com/exercism/rikki/analysis/ruby"
+)
+
+// Config is everything rikki- needs to know to run.
+//
+// Settings are resolved in order of increasing precedence:
+// built-in defaults, the TOML config file, environment variables, and flags.
+type Config struct {
+	Env        string                 `toml:"env"`
+	Exercism   ExercismConfig         `toml:"exercism"`
+	Analyzers  AnalyzersConfig        `toml:"analyzers"`
+	Comments   string                 `toml:"comments"`
+	Secret     string                 `toml:"secret"`
+	SecretFile string                 `toml:"secret_file"`
+	Retired    []string               `toml:"retired_secrets"`
+	HTTP       HTTPConfig             `toml:"http"`
+	Log        LogConfig              `toml:"log"`
+	Redis      RedisConfig            `toml:"redis"`
+	Queues     []QueueConfig          `toml:"queue"`
+	Tracks     map[string]TrackConfig `toml:"track"`
+	DB         string                 `toml:"db"`
+	Cache      CacheConfig            `toml:"cache"`
+	Limits     CommentLimitsConfig    `toml:"comment_limits"`
+	Mentor     MentorConfig           `toml:"mentor"`
+	Votes      VotesConfig            `toml:"votes"`
+
+	// secretFrom says where the secret came from, for the logs.
+	secretFrom string
+	// unknown lists keys in the config file that don't match any setting.
+	unknown []string
+}
+
+// ExercismConfig configures the client for the exercism API.
+//
+// Auth is either "hmac", to sign each request, or "shared-key", to send the
+// SHA1 of the secret for servers that can't verify signatures yet.
+// LegacyAuth also sends the shared key as a query parameter, for servers
+// that don't read it from the Authorization header yet.
+type ExercismConfig struct {
+	URL        string   `toml:"url"`
+	Timeout    duration `toml:"timeout"`
+	Auth       string   `toml:"auth"`
+	LegacyAuth bool     `toml:"legacy_auth"`
+}
+
+// duration is a time.Duration written as a string, e.g. "10s", in the config file.
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalText(text []byte) (err error) {
+	d.Duration, err = time.ParseDuration(string(text))
+	return
+}
+
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// AnalyzersConfig points to the remote analysis APIs.
+type AnalyzersConfig struct {
+	Ruby    string   `toml:"ruby"`
+	Crystal string   `toml:"crystal"`
+	Timeout duration `toml:"timeout"`
+}
+
+// HTTPConfig configures the embedded HTTP server that exposes health checks
+// and metrics.
+// An empty address disables the server.
+type HTTPConfig struct {
+	Addr string `toml:"addr"`
+}
+
+// LogConfig configures the level and format of the logs.
+type LogConfig struct {
+	Level  string `toml:"level"`
+	Format string `toml:"format"`
+}
+
+// RedisConfig configures the connection to the redis queue.
+type RedisConfig struct {
+	URL  string `toml:"url"`
+	Pool int    `toml:"pool"`
+}
+
+// QueueConfig binds a queue to the job that processes its messages.
+type QueueConfig struct {
+	Name        string `toml:"name"`
+	Job         string `toml:"job"`
+	Concurrency int    `toml:"concurrency"`
+}
+
+// TrackConfig limits how many analyses of a single track may run at once.
+// This keeps slow remote analyzers from tying up every worker.
+// A concurrency of zero means there is no limit.
+type TrackConfig struct {
+	Concurrency int `toml:"concurrency"`
+}
+
+// CacheConfig limits the cache of analyses of code rikki- has seen before.
+// A size of zero disables the cache.
+type CacheConfig struct {
+	Size int      `toml:"size"`
+	TTL  duration `toml:"ttl"`
+}
+
+// CommentLimitsConfig caps the comments posted a minute, to each student,
+// on each track, and overall. A limit of zero means there is none.
+// Over says what happens to comments over a limit: "defer" puts the job
+// back on the queue until the next minute, "drop" doesn't post them.
+type CommentLimitsConfig struct {
+	Student int    `toml:"student"`
+	Track   int    `toml:"track"`
+	Global  int    `toml:"global"`
+	Over    string `toml:"over"`
+}
+
+// MentorConfig chooses findings to leave as private notes for mentors,
+// instead of posting them as comments for the student: every finding on
+// the Tracks, and findings about smells of the Severities on any track.
+type MentorConfig struct {
+	Tracks     []string `toml:"tracks"`
+	Severities []string `toml:"severities"`
+}
+
+// VotesConfig enables students to vote on whether a comment helped.
+// Votes are taken on their own listener at Addr, apart from the internal
+// endpoints, and URL is where students can reach it.
+// An empty URL disables votes.
+type VotesConfig struct {
+	URL  string `toml:"url"`
+	Addr string `toml:"addr"`
+}
+
+// defaultConfig is what rikki- does out of the box, talking to
+// everything on localhost.
+func defaultConfig() *Config {
+	return &Config{
+		Env: "development",
+		Exercism: ExercismConfig{
+			URL:     "http://localhost:4567",
+			Timeout: duration{10 * time.Second},
+			Auth:    "hmac",
+		},
+		Analyzers: AnalyzersConfig{
+			Ruby:    "http://localhost:8989",
+			Crystal: "http://localhost:3000",
+			Timeout: duration{10 * time.Second},
+		},
+		Comments: "comments",
+		HTTP:     HTTPConfig{Addr: ":9292"},
+		Log:      LogConfig{Level: "info", Format: "json"},
+		Redis: RedisConfig{
+			URL:  "redis://localhost:6379/0/",
+			Pool: 30,
+		},
+		Queues: []QueueConfig{
+			{Name: "analyze", Job: "analyze", Concurrency: 4},
+			{Name: "hello", Job: "hello", Concurrency: 4},
+		},
+		Tracks: map[string]TrackConfig{},
+		DB:     "rikki.db",
+		Cache:  CacheConfig{Size: 1000, TTL: duration{time.Hour}},
+		Limits: CommentLimitsConfig{Over: "defer"},
+		Votes:  VotesConfig{Addr: ":9393"},
+	}
+}
+
+// envVars maps environment variables to the setting they override.
+var envVars = []struct {
+	name string
+	fn   func(*Config, string) error
+}{
+	{"RIKKI_EXERCISM", func(c *Config, v string) error { c.Exercism.URL = v; return nil }},
+	{"RIKKI_EXERCISM_TIMEOUT", func(c *Config, v string) error { return c.Exercism.Timeout.UnmarshalText([]byte(v)) }},
+	{"RIKKI_EXERCISM_AUTH", func(c *Config, v string) error { c.Exercism.Auth = v; return nil }},
+	{"RIKKI_EXERCISM_LEGACY_AUTH", func(c *Config, v string) (err error) { c.Exercism.LegacyAuth, err = strconv.ParseBool(v); return }},
+	{"RIKKI_RUBY_ANALYZER", func(c *Config, v string) error { c.Analyzers.Ruby = v; return nil }},
+	{"RIKKI_CRYSTAL_ANALYZER", func(c *Config, v string) error { c.Analyzers.Crystal = v; return nil }},
+	{"RIKKI_ANALYZER_TIMEOUT", func(c *Config, v string) error { return c.Analyzers.Timeout.UnmarshalText([]byte(v)) }},
+	{"RIKKI_FEEDBACK_DIR", func(c *Config, v string) error { c.Comments = v; return nil }},
+	{"RIKKI_ENV", func(c *Config, v string) error { c.Env = v; return nil }},
+	{"RIKKI_SECRET", func(c *Config, v string) error { c.Secret, c.secretFrom = v, "RIKKI_SECRET"; return nil }},
+	{"RIKKI_SECRET_FILE", func(c *Config, v string) error { c.SecretFile = v; return nil }},
+	{"RIKKI_RETIRED_SECRETS", func(c *Config, v string) error { c.Retired = strings.Split(v, ","); return nil }},
+	{"RIKKI_HTTP", func(c *Config, v string) error { c.HTTP.Addr = v; return nil }},
+	{"RIKKI_LOG_LEVEL", func(c *Config, v string) error { c.Log.Level = v; return nil }},
+	{"RIKKI_LOG_FORMAT", func(c *Config, v string) error { c.Log.Format = v; return nil }},
+	{"RIKKI_REDIS", func(c *Config, v string) error { c.Redis.URL = v; return nil }},
+	{"RIKKI_REDIS_POOL", func(c *Config, v string) (err error) { c.Redis.Pool, err = strconv.Atoi(v); return }},
+	{"RIKKI_CACHE_SIZE", func(c *Config, v string) (err error) { c.Cache.Size, err = strconv.Atoi(v); return }},
+	{"RIKKI_CACHE_TTL", func(c *Config, v string) error { return c.Cache.TTL.UnmarshalText([]byte(v)) }},
+	{"RIKKI_COMMENT_LIMIT_STUDENT", func(c *Config, v string) (err error) { c.Limits.Student, err = strconv.Atoi(v); return }},
+	{"RIKKI_COMMENT_LIMIT_TRACK", func(c *Config, v string) (err error) { c.Limits.Track, err = strconv.Atoi(v); return }},
+	{"RIKKI_COMMENT_LIMIT_GLOBAL", func(c *Config, v string) (err error) { c.Limits.Global, err = strconv.Atoi(v); return }},
+	{"RIKKI_COMMENT_LIMIT_OVER", func(c *Config, v string) error { c.Limits.Over = v; return nil }},
+	{"RIKKI_MENTOR_TRACKS", func(c *Config, v string) error { c.Mentor.Tracks = strings.Split(v, ","); return nil }},
+	{"RIKKI_MENTOR_SEVERITIES", func(c *Config, v string) error { c.Mentor.Severities = strings.Split(v, ","); return nil }},
+	{"RIKKI_DB", func(c *Config, v string) error { c.DB = v; return nil }},
+	{"RIKKI_VOTES_URL", func(c *Config, v string) error { c.Votes.URL = v; return nil }},
+	{"RIKKI_VOTES_ADDR", func(c *Config, v string) error { c.Votes.Addr = v; return nil }},
+}
+
+// loadConfig resolves the effective configuration.
+// An empty path skips the config file.
+func loadConfig(path string, fs *flag.FlagSet) (*Config, error) {
+	config := defaultConfig()
+
+	if path != "" {
+		// Queues in the file replace the defaults rather than adding to them.
+		queues := config.Queues
+		config.Queues = nil
+		md, err := toml.DecodeFile(path, config)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read config %s - %s", path, err)
+		}
+		for _, key := range md.Undecoded() {
+			config.unknown = append(config.unknown, key.String())
+		}
+		if config.Secret != "" {
+			config.secretFrom = "config file " + path
+		}
+		if len(config.Queues) == 0 {
+			config.Queues = queues
+		}
+		if config.Tracks == nil {
+			config.Tracks = map[string]TrackConfig{}
+		}
+	}
+
+	for _, env := range envVars {
+		v := os.Getenv(env.name)
+		if v == "" {
+			continue
+		}
+		if err := env.fn(config, v); err != nil {
+			return nil, fmt.Errorf("invalid value for %s - %s", env.name, err)
+		}
+	}
+
+	if fs != nil {
+		applyFlags(config, fs)
+	}
+
+	if err := config.readSecretFile(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// readSecretFile loads the secret from secret_file, if one is configured.
+// Surrounding whitespace, such as a trailing newline, is ignored.
+func (config *Config) readSecretFile() error {
+	if config.SecretFile == "" {
+		return nil
+	}
+	if config.Secret != "" {
+		return fmt.Errorf("secret is set by %s and also by secret file %s; set only one", config.secretFrom, config.SecretFile)
+	}
+	b, err := ioutil.ReadFile(config.SecretFile)
+	if err != nil {
+		return fmt.Errorf("cannot read secret file - %s", err)
+	}
+	config.Secret = strings.TrimSpace(string(b))
+	config.secretFrom = "secret file " + config.SecretFile
+	return nil
+}
+
+// secretSource says where the shared secret was taken from.
+func (config *Config) secretSource() string {
+	if config.Secret == "" {
+		return "development default"
+	}
+	return config.secretFrom
+}
+
+// applyFlags overrides the configuration with the flags that were explicitly set.
+// Flags left at their default do not clobber the file or the environment.
+func applyFlags(config *Config, fs *flag.FlagSet) {
+	fs.Visit(func(f *flag.Flag) {
+		v := f.Value.String()
+		switch f.Name {
+		case "exercism":
+			config.Exercism.URL = v
+		case "ruby-analyzer":
+			config.Analyzers.Ruby = v
+		case "crystal-analyzer":
+			config.Analyzers.Crystal = v
+		case "env":
+			config.Env = v
+		case "exercism-auth":
+			config.Exercism.Auth = v
+		case "http":
+			config.HTTP.Addr = v
+		case "log-level":
+			config.Log.Level = v
+		case "redis":
+			config.Redis.URL = v
+		case "pool":
+			config.Redis.Pool = f.Value.(flag.Getter).Get().(int)
+		case "queue":
+			config.Queues = *f.Value.(*queueFlag)
+		case "track-limit":
+			for track, n := range f.Value.(trackFlag) {
+				config.Tracks[track] = TrackConfig{Concurrency: n}
+			}
+		}
+	})
+}
+
+func (config *Config) validate(jobs map[string]bool) error {
+	if len(config.unknown) > 0 {
+		return fmt.Errorf("unknown settings in config file: %s", strings.Join(config.unknown, ", "))
+	}
+	switch config.Env {
+	case "development":
+	case "production":
+		if config.Secret == "" || config.Secret == devSecret {
+			return fmt.Errorf("refusing to run in production without a secret; set RIKKI_SECRET or RIKKI_SECRET_FILE")
+		}
+	default:
+		return fmt.Errorf("env must be development or production, got %q", config.Env)
+	}
+	urls := []struct {
+		name, value string
+	}{
+		{"exercism url", config.Exercism.URL},
+		{"ruby analyzer url", config.Analyzers.Ruby},
+		{"crystal analyzer url", config.Analyzers.Crystal},
+		{"redis url", config.Redis.URL},
+	}
+	if config.Votes.URL != "" {
+		urls = append(urls, struct{ name, value string }{"votes url", config.Votes.URL})
+		if config.Votes.Addr == "" {
+			return fmt.Errorf("votes need an address to listen on")
+		}
+		if config.Votes.Addr == config.HTTP.Addr {
+			return fmt.Errorf("votes must not share the %s listener with the internal endpoints", config.Votes.Addr)
+		}
+	}
+	for _, u := range urls {
+		if err := validateURL(u.value); err != nil {
+			return fmt.Errorf("%s - %s", u.name, err)
+		}
+	}
+	if config.Exercism.Auth != "hmac" && config.Exercism.Auth != "shared-key" {
+		return fmt.Errorf("exercism auth must be hmac or shared-key, got %q", config.Exercism.Auth)
+	}
+	if config.Exercism.Timeout.Duration <= 0 {
+		return fmt.Errorf("exercism timeout must be positive, got %s", config.Exercism.Timeout)
+	}
+	if config.Analyzers.Timeout.Duration <= 0 {
+		return fmt.Errorf("analyzer timeout must be positive, got %s", config.Analyzers.Timeout)
+	}
+	if config.Comments == "" {
+		return fmt.Errorf("no comments directory configured")
+	}
+	if info, err := os.Stat(config.Comments); err != nil || !info.IsDir() {
+		return fmt.Errorf("comments directory %s does not exist", config.Comments)
+	}
+	if config.Cache.Size < 0 {
+		return fmt.Errorf("cache size cannot be negative, got %d", config.Cache.Size)
+	}
+	if config.Cache.TTL.Duration < 0 {
+		return fmt.Errorf("cache ttl cannot be negative, got %s", config.Cache.TTL)
+	}
+	if config.Limits.Student < 0 || config.Limits.Track < 0 || config.Limits.Global < 0 {
+		return fmt.Errorf("comment limits cannot be negative")
+	}
+	if config.Limits.Over != "defer" && config.Limits.Over != "drop" {
+		return fmt.Errorf("comments over the limit must be deferred or dropped, got %q", config.Limits.Over)
+	}
+	for _, severity := range config.Mentor.Severities {
+		switch analysis.Severity(severity) {
+		case analysis.Info, analysis.Warning, analysis.Blocking:
+		default:
+			return fmt.Errorf("unknown severity %q for mentor notes; use info, warning or blocking", severity)
+		}
+	}
+	if config.DB == "" {
+		return fmt.Errorf("no database file configured")
+	}
+	if config.Redis.Pool < 1 {
+		return fmt.Errorf("redis pool must be at least 1, got %d", config.Redis.Pool)
+	}
+	if len(config.Queues) == 0 {
+		return fmt.Errorf("no queues configured")
+	}
+	seen := map[string]bool{}
+	for _, q := range config.Queues {
+		if q.Name == "" {
+			return fmt.Errorf("queue with job %q has no name", q.Job)
+		}
+		if seen[q.Name] {
+			return fmt.Errorf("queue %s is configured more than once", q.Name)
+		}
+		seen[q.Name] = true
+		if !jobs[q.Job] {
+			return fmt.Errorf("queue %s - unknown job %q", q.Name, q.Job)
+		}
+		if q.Concurrency < 1 {
+			return fmt.Errorf("queue %s - concurrency must be at least 1, got %d", q.Name, q.Concurrency)
+		}
+	}
+	for track, t := range config.Tracks {
+		if t.Concurrency < 0 {
+			return fmt.Errorf("track %s - concurrency cannot be negative, got %d", track, t.Concurrency)
+		}
+	}
+	return nil
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute url", s)
+	}
+	return nil
+}
+
+// print writes the configuration as TOML, with secrets masked.
+func (config *Config) print(w io.Writer) error {
+	c := *config
+	c.Secret = mask(c.Secret)
+	c.Retired = nil
+	for _, secret := range config.Retired {
+		c.Retired = append(c.Retired, mask(secret))
+	}
+	u, err := url.Parse(c.Redis.URL)
+	if err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			c.Redis.URL = u.String()
+		}
+	}
+	return toml.NewEncoder(w).Encode(c)
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
+
+// queueFlag collects repeated -queue flags of the form name=job:concurrency.
+type queueFlag []QueueConfig
+
+func (f *queueFlag) String() string {
+	var s []string
+	for _, q := range *f {
+		s = append(s, fmt.Sprintf("%s=%s:%d", q.Name, q.Job, q.Concurrency))
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *queueFlag) Set(value string) error {
+	q, err := parseQueue(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, q)
+	return nil
+}
+
+func parseQueue(s string) (QueueConfig, error) {
+	var q QueueConfig
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return q, fmt.Errorf("queue %q should look like name=job:concurrency", s)
+	}
+	q.Name, q.Job = s[:i], s[i+1:]
+	q.Concurrency = 1
+	if j := strings.LastIndex(q.Job, ":"); j > -1 {
+		n, err := strconv.Atoi(q.Job[j+1:])
+		if err != nil {
+			return q, fmt.Errorf("queue %q has an invalid concurrency - %s", s, err)
+		}
+		q.Job, q.Concurrency = q.Job[:j], n
+	}
+	return q, nil
+}
+
+// trackFlag collects repeated -track-limit flags of the form track=concurrency.
+type trackFlag map[string]int
+
+func (f trackFlag) String() string {
+	var s []string
+	for track, n := range f {
+		s = append(s, fmt.Sprintf("%s=%d", track, n))
+	}
+	return strings.Join(s, ",")
+}
+
+func (f trackFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i < 1 {
+		return fmt.Errorf("track limit %q should look like track=concurrency", value)
+	}
+	n, err := strconv.Atoi(value[i+1:])
+	if err != nil {
+		return fmt.Errorf("track limit %q has an invalid concurrency - %s", value, err)
+	}
+	f[value[:i]] = n
+	return nil
+}
+
+// votes configures students' votes on comments, or returns nil if they're disabled.
+func (config *Config) votes(store *store) *votes {
+	if config.Votes.URL == "" {
+		return nil
+	}
+	return newVotes(config.Votes.URL, NewAuth(config.Secret, config.Retired), store)
+}
+
+// exercism configures a client for the exercism API.
+func (config *Config) exercism() *Exercism {
+	client := &http.Client{Timeout: config.Exercism.Timeout.Duration}
+	return NewExercism(config.Exercism.URL, config.signer(), client)
+}
+
+// configureAnalyzers points the analysis packages at the remote analyzers.
+func (config *Config) configureAnalyzers() {
+	ruby.Host = config.Analyzers.Ruby
+	crystal.Host = config.Analyzers.Crystal
+	ruby.Client = &http.Client{Timeout: config.Analyzers.Timeout.Duration}
+	crystal.Client = &http.Client{Timeout: config.Analyzers.Timeout.Duration}
+}
+
+// signer is how requests to the exercism API are authenticated.
+func (config *Config) signer() Signer {
+	auth := NewAuth(config.Secret, config.Retired)
+	if config.Exercism.Auth == "shared-key" {
+		return SharedKey{Key: auth.Key(), Legacy: config.Exercism.LegacyAuth}
+	}
+	return auth
+}
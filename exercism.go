@@ -2,27 +2,39 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
 )
 
 // Exercism is a client that talks to the exercism API.
+// Every request is authenticated by the Signer.
 type Exercism struct {
-	Host string
-	Auth string
+	Host   string
+	Auth   Signer
+	Client *http.Client
 }
 
 // NewExercism creates an exercism client, configured to talk to the API.
-func NewExercism(host, auth string) *Exercism {
-	return &Exercism{Host: host, Auth: auth}
+// If client is nil, requests are made with http.DefaultClient.
+func NewExercism(host string, auth Signer, client *http.Client) *Exercism {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Exercism{Host: host, Auth: auth, Client: client}
 }
 
 type codePayload struct {
 	TrackID       string            `json:"track_id"`
 	SolutionFiles map[string]string `json:"solution_files"`
 	Slug          string            `json:"slug"`
+	Locale        string            `json:"locale"`
+	Username      string            `json:"username"`
 	Error         string            `json:"error"`
 }
 
@@ -30,69 +42,161 @@ type commentBody struct {
 	Comment string `json:"comment"`
 }
 
+type noteBody struct {
+	Note string `json:"note"`
+}
+
 // Solution is an iteration of a specific problem in a particular language.
+// Locale is the language the student would like feedback in, if they said.
+// Username identifies the student who submitted it.
 type Solution struct {
-	TrackID string
-	Files   map[string]string
-	Slug    string
+	UUID     string
+	TrackID  string
+	Files    map[string]string
+	Slug     string
+	Locale   string
+	Username string
 }
 
-// FetchSolution fetches the code of a solution from the exercism API.
-func (e *Exercism) FetchSolution(uuid string) (*Solution, error) {
-	url := fmt.Sprintf("%s/api/v1/submissions/%s", e.Host, uuid)
-	req, err := http.NewRequest("GET", url, nil)
+// APIError is an unexpected response from the exercism API.
+type APIError struct {
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e APIError) Error() string {
+	return fmt.Sprintf("%s responded with status %d - %s", e.URL, e.StatusCode, e.Body)
+}
+
+// NotFoundError means the API doesn't know about the submission.
+type NotFoundError struct{ APIError }
+
+// UnauthorizedError means the API rejected our credentials.
+type UnauthorizedError struct{ APIError }
+
+// RateLimitedError means we're making too many requests.
+// RetryAfter is how long the API asked us to wait, if it said.
+type RateLimitedError struct {
+	APIError
+	RetryAfter time.Duration
+}
+
+// Temporary reports that the request may succeed if retried later.
+func (e *RateLimitedError) Temporary() bool { return true }
+
+// ServerError means the API failed to handle the request.
+type ServerError struct{ APIError }
+
+// Temporary reports that the request may succeed if retried later.
+func (e *ServerError) Temporary() bool { return true }
+
+// apiError classifies an unexpected response.
+func apiError(url string, resp *http.Response, body []byte) error {
+	e := APIError{URL: url, StatusCode: resp.StatusCode, Body: string(body)}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return &NotFoundError{e}
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		return &UnauthorizedError{e}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		var wait time.Duration
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(secs) * time.Second
+		}
+		return &RateLimitedError{APIError: e, RetryAfter: wait}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return &ServerError{e}
+	}
+	return &e
+}
+
+// retryable reports whether a failed request is worth trying again later.
+// This covers rate limiting, server errors, and network timeouts.
+func retryable(err error) bool {
+	t, ok := err.(interface {
+		Temporary() bool
+	})
+	return ok && t.Temporary()
+}
+
+// do sends a request to the API, and returns the body of the response
+// if it has the expected status.
+// The endpoint names the call in metrics.
+func (e *Exercism) do(ctx context.Context, endpoint, method, url string, body []byte, want int) (_ []byte, err error) {
+	var status int
+	defer func(start time.Time) { observeAPI(endpoint, start, status, err) }(time.Now())
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("cannot prepare request to %s - %s\n", url, err)
+		return nil, fmt.Errorf("cannot prepare request to %s - %s", url, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := e.Auth.Sign(req, body); err != nil {
+		return nil, fmt.Errorf("cannot sign request to %s - %s", url, err)
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := e.Client.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("request to %s failed - %s\n", url, err)
+		if ue, ok := err.(*neturl.Error); ok {
+			// Keep the shared key out of the logs.
+			ue.URL = url
+		}
+		return nil, err
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
 	if err != nil {
-		return nil, fmt.Errorf("cannot read response - %s\n", err)
+		return nil, fmt.Errorf("cannot read response from %s - %s", url, err)
 	}
-	resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s responded with status %d - %s\n", url, resp.StatusCode, string(body))
+	status = resp.StatusCode
+	if resp.StatusCode != want {
+		return nil, apiError(url, resp, b)
+	}
+	return b, nil
+}
+
+// FetchSolution fetches the code of a solution from the exercism API.
+func (e *Exercism) FetchSolution(ctx context.Context, uuid string) (*Solution, error) {
+	url := fmt.Sprintf("%s/api/v1/submissions/%s", e.Host, uuid)
+	body, err := e.do(ctx, "fetch_solution", "GET", url, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
 	}
 
 	var cp codePayload
 	if err := json.Unmarshal(body, &cp); err != nil {
-		return nil, fmt.Errorf("%s - %s\n", uuid, err)
+		return nil, fmt.Errorf("%s - %s", uuid, err)
 	}
 
-	return &Solution{TrackID: cp.TrackID, Slug: cp.Slug, Files: cp.SolutionFiles}, nil
+	return &Solution{UUID: uuid, TrackID: cp.TrackID, Slug: cp.Slug, Files: cp.SolutionFiles, Locale: cp.Locale, Username: cp.Username}, nil
 }
 
 // SubmitComment submits a rikki- comment to a particular submission via the exercism API.
-func (e *Exercism) SubmitComment(comment []byte, uuid string) error {
-	experiment := "_This is an automated review based on lots and lots of real-life reviews. [Read more](http://exercism.io/rikki) about this experiment._"
-	s := fmt.Sprintf("%s\n-----\n%s", string(comment), experiment)
-
-	cb, err := json.Marshal(&commentBody{Comment: s})
+// The comment is posted as it is, so it should already be signed with the footer.
+func (e *Exercism) SubmitComment(ctx context.Context, comment []byte, uuid string) error {
+	cb, err := json.Marshal(&commentBody{Comment: string(comment)})
 	if err != nil {
 		return err
 	}
 
-	url := fmt.Sprintf("%s/api/v1/submissions/%s/comments?shared_key=%s", e.Host, uuid, e.Auth)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(cb))
-	if err != nil {
-		return fmt.Errorf("cannot prepare request to %s - %s", url, err)
-	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("request to %s failed - %s", url, err)
-	}
+	url := fmt.Sprintf("%s/api/v1/submissions/%s/comments", e.Host, uuid)
+	_, err = e.do(ctx, "submit_comment", "POST", url, cb, http.StatusNoContent)
+	return err
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// SubmitNote leaves a private note about a solution for its mentors.
+// The student doesn't see it.
+func (e *Exercism) SubmitNote(ctx context.Context, note []byte, uuid string) error {
+	nb, err := json.Marshal(&noteBody{Note: string(note)})
 	if err != nil {
-		return fmt.Errorf("cannot read response body %#v - %s", resp, err)
-	}
-	resp.Body.Close()
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("%s responded with status %d - %s", url, resp.StatusCode, string(body))
+		return err
 	}
-	return nil
+
+	url := fmt.Sprintf("%s/api/v1/submissions/%s/mentor_notes", e.Host, uuid)
+	_, err = e.do(ctx, "submit_note", "POST", url, nb, http.StatusNoContent)
+	return err
 }
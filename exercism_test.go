@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/exercism/rikki/exercismtest"
+)
+
+func TestFetchSolution(t *testing.T) {
+	api := exercismtest.NewServer()
+	defer api.Close()
+
+	files := map[string]string{"leap.go": "package leap"}
+	api.AddSubmission("abc", exercismtest.Submission{TrackID: "go", Slug: "leap", Files: files})
+
+	solution, err := NewExercism(api.URL, SharedKey{Key: "key"}, nil).FetchSolution(context.Background(), "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if solution.TrackID != "go" || solution.Slug != "leap" || !reflect.DeepEqual(solution.Files, files) {
+		t.Errorf("unexpected solution %#v", solution)
+	}
+}
+
+func TestSubmitComment(t *testing.T) {
+	api := exercismtest.NewServer()
+	defer api.Close()
+	api.AddSubmission("abc", exercismtest.Submission{TrackID: "go", Slug: "leap"})
+
+	if err := NewExercism(api.URL, SharedKey{Key: "key"}, nil).SubmitComment(context.Background(), []byte("Nice!"), "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	comments := api.Comments()
+	if len(comments) != 1 {
+		t.Fatalf("got %d comments, want 1", len(comments))
+	}
+	if comments[0].Body != "Nice!" {
+		t.Errorf("unexpected comment %q", comments[0].Body)
+	}
+}
+
+func TestExercismErrors(t *testing.T) {
+	tests := []struct {
+		status     int
+		retryAfter int
+		retryable  bool
+		check      func(error) bool
+	}{
+		{http.StatusNotFound, 0, false, func(err error) bool { _, ok := err.(*NotFoundError); return ok }},
+		{http.StatusUnauthorized, 0, false, func(err error) bool { _, ok := err.(*UnauthorizedError); return ok }},
+		{http.StatusForbidden, 0, false, func(err error) bool { _, ok := err.(*UnauthorizedError); return ok }},
+		{http.StatusTooManyRequests, 30, true, func(err error) bool {
+			e, ok := err.(*RateLimitedError)
+			return ok && e.RetryAfter == 30*time.Second
+		}},
+		{http.StatusBadGateway, 0, true, func(err error) bool { _, ok := err.(*ServerError); return ok }},
+		{http.StatusTeapot, 0, false, func(err error) bool { _, ok := err.(*APIError); return ok }},
+	}
+
+	for _, test := range tests {
+		api := exercismtest.NewServer()
+		api.AddSubmission("abc", exercismtest.Submission{TrackID: "go"})
+		api.Fail(exercismtest.Failure{Status: test.status, RetryAfter: test.retryAfter})
+
+		_, err := NewExercism(api.URL, SharedKey{Key: "key"}, nil).FetchSolution(context.Background(), "abc")
+		api.Close()
+
+		if !test.check(err) {
+			t.Errorf("status %d: got unexpected error %#v", test.status, err)
+		}
+		if retryable(err) != test.retryable {
+			t.Errorf("status %d: retryable - got: %t, want: %t", test.status, retryable(err), test.retryable)
+		}
+	}
+}
+
+func TestExercismTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Timeout: 10 * time.Millisecond}
+	_, err := NewExercism(ts.URL, SharedKey{Key: "key"}, client).FetchSolution(context.Background(), "abc")
+	if err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if !retryable(err) {
+		t.Errorf("a timeout should be retryable - %s", err)
+	}
+}
+
+func TestExercismSharedKey(t *testing.T) {
+	tests := []struct {
+		legacy bool
+		query  string
+	}{
+		{false, ""},
+		{true, "key"},
+	}
+
+	for _, test := range tests {
+		var requests []*http.Request
+		api := exercismtest.NewServer()
+		api.AddSubmission("abc", exercismtest.Submission{TrackID: "go"})
+		api.Authenticate = func(r *http.Request, body []byte) error {
+			requests = append(requests, r)
+			return nil
+		}
+
+		e := NewExercism(api.URL, SharedKey{Key: "key", Legacy: test.legacy}, nil)
+		if _, err := e.FetchSolution(context.Background(), "abc"); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.SubmitComment(context.Background(), []byte("hi"), "abc"); err != nil {
+			t.Fatal(err)
+		}
+		api.Close()
+
+		for _, r := range requests {
+			if got := r.Header.Get("Authorization"); got != "Token key" {
+				t.Errorf("%s %s: Authorization - got: %q, want: %q", r.Method, r.URL.Path, got, "Token key")
+			}
+			if got := r.URL.Query().Get("shared_key"); got != test.query {
+				t.Errorf("%s %s legacy=%t: shared_key - got: %q, want: %q", r.Method, r.URL.Path, test.legacy, got, test.query)
+			}
+		}
+	}
+}
+
+func TestExercismSigned(t *testing.T) {
+	auth := NewAuth("s3cret", nil)
+
+	api := exercismtest.NewServer()
+	defer api.Close()
+	api.AddSubmission("abc", exercismtest.Submission{TrackID: "go"})
+	api.Authenticate = func(r *http.Request, body []byte) error { return verifySignature("s3cret", r, body) }
+
+	e := NewExercism(api.URL, auth, nil)
+	if _, err := e.FetchSolution(context.Background(), "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SubmitComment(context.Background(), []byte("hi"), "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	e = NewExercism(api.URL, NewAuth("wrong", nil), nil)
+	if _, err := e.FetchSolution(context.Background(), "abc"); err == nil {
+		t.Error("expected a request signed with the wrong secret to be rejected")
+	}
+}
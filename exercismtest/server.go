@@ -0,0 +1,203 @@
+// Package exercismtest provides a fake exercism API for testing rikki- offline.
+//
+// The server implements the endpoints rikki- uses: fetching a submission,
+// posting a comment on it, and leaving a private note for mentors. It records
+// the comments and notes it receives, and can be scripted to fail.
+package exercismtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Submission is a solution the fake API knows about.
+// Locale is the language the student prefers, if any.
+type Submission struct {
+	TrackID  string
+	Slug     string
+	Files    map[string]string
+	Locale   string
+	Username string
+}
+
+// Comment is a comment, or a note for mentors, that was posted to the fake API.
+type Comment struct {
+	UUID   string
+	Body   string
+	Header http.Header
+}
+
+// Failure makes requests respond with an error status instead of the usual response.
+// It applies to requests with the given method whose path contains Path,
+// and is used up after Times requests. A Times of zero means it never wears off.
+type Failure struct {
+	Method     string
+	Path       string
+	Status     int
+	Body       string
+	RetryAfter int
+	Times      int
+}
+
+// Server is a fake exercism API.
+type Server struct {
+	*httptest.Server
+
+	// Authenticate, if set, is called with every request and its body.
+	// Requests for which it returns an error are rejected as unauthorized.
+	Authenticate func(r *http.Request, body []byte) error
+
+	mu          sync.Mutex
+	submissions map[string]Submission
+	comments    []Comment
+	notes       []Comment
+	failures    []*Failure
+	requests    int
+}
+
+// NewServer starts a fake exercism API. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		submissions: map[string]Submission{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// AddSubmission makes a submission available to fetch.
+func (s *Server) AddSubmission(uuid string, sub Submission) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.submissions[uuid] = sub
+}
+
+// Fail scripts a failure.
+// Failures are checked in the order they were added.
+func (s *Server) Fail(f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &f)
+}
+
+// Comments returns the comments posted so far.
+func (s *Server) Comments() []Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Comment(nil), s.comments...)
+}
+
+// Notes returns the notes for mentors posted so far.
+func (s *Server) Notes() []Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Comment(nil), s.notes...)
+}
+
+// Requests returns the number of requests the server has handled.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if f := s.failure(r); f != nil {
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(f.RetryAfter))
+		}
+		http.Error(w, f.Body, f.Status)
+		return
+	}
+
+	if s.Authenticate != nil {
+		if err := s.Authenticate(r, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) < 4 || segments[0] != "api" || segments[1] != "v1" || segments[2] != "submissions" {
+		http.NotFound(w, r)
+		return
+	}
+	uuid := segments[3]
+
+	switch {
+	case len(segments) == 4 && r.Method == "GET":
+		s.fetch(w, uuid)
+	case len(segments) == 5 && segments[4] == "comments" && r.Method == "POST":
+		s.post(w, r, uuid, body, "comment", &s.comments)
+	case len(segments) == 5 && segments[4] == "mentor_notes" && r.Method == "POST":
+		s.post(w, r, uuid, body, "note", &s.notes)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// failure finds the first scripted failure that applies to the request, and uses it up.
+func (s *Server) failure(r *http.Request) *Failure {
+	for i, f := range s.failures {
+		if f.Method != "" && f.Method != r.Method {
+			continue
+		}
+		if !strings.Contains(r.URL.Path, f.Path) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) fetch(w http.ResponseWriter, uuid string) {
+	sub, ok := s.submissions[uuid]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "submission not found"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		TrackID       string            `json:"track_id"`
+		Slug          string            `json:"slug"`
+		SolutionFiles map[string]string `json:"solution_files"`
+		Locale        string            `json:"locale,omitempty"`
+		Username      string            `json:"username,omitempty"`
+	}{sub.TrackID, sub.Slug, sub.Files, sub.Locale, sub.Username})
+}
+
+// post records the text in the field of a JSON body.
+func (s *Server) post(w http.ResponseWriter, r *http.Request, uuid string, body []byte, field string, into *[]Comment) {
+	if _, ok := s.submissions[uuid]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(body, &fields); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	*into = append(*into, Comment{UUID: uuid, Body: fields[field], Header: r.Header})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// footerSeparator sets the footer apart from the comment.
+const footerSeparator = "\n-----\n"
+
+// issuesURL is where students can tell us about a comment that didn't help.
+const issuesURL = "https://github.com/exercism/rikki/issues/new"
+
+// footer signs every comment rikki- posts.
+//
+// The footer is a template in the comment library, footer.md, which a track
+// can override with footer/<track>.md. Templates are given a footerData.
+// A nil footer leaves comments as they are.
+type footer struct {
+	base   *template.Template
+	tracks map[string]*template.Template
+}
+
+// footerData is what a footer template can say about the comment.
+// HelpfulURL and UnhelpfulURL are only set when votes are enabled.
+type footerData struct {
+	Track        string
+	Smell        string
+	Variant      string
+	UUID         string
+	FeedbackURL  string
+	HelpfulURL   string
+	UnhelpfulURL string
+}
+
+// loadFooter parses the footer templates in the comment library.
+// Without a footer.md, comments are posted without a footer.
+func loadFooter(dir string) (*footer, error) {
+	base, err := parseFooter(filepath.Join(dir, "footer.md"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := &footer{base: base, tracks: map[string]*template.Template{}}
+	paths, err := filepath.Glob(filepath.Join(dir, "footer", "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		t, err := parseFooter(path)
+		if err != nil {
+			return nil, err
+		}
+		f.tracks[strings.TrimSuffix(filepath.Base(path), ".md")] = t
+	}
+	return f, nil
+}
+
+func parseFooter(path string) (*template.Template, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(filepath.Base(path)).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("invalid footer %s - %s", path, err)
+	}
+	return t, nil
+}
+
+// sign appends the footer for the track to a comment.
+func (f *footer) sign(comment []byte, data footerData) ([]byte, error) {
+	if f == nil {
+		return comment, nil
+	}
+	if data.FeedbackURL == "" {
+		data.FeedbackURL = feedbackURL(data)
+	}
+	t, ok := f.tracks[data.Track]
+	if !ok {
+		t = f.base
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	s := strings.TrimSpace(buf.String())
+	if s == "" {
+		return comment, nil
+	}
+	return []byte(string(comment) + footerSeparator + s), nil
+}
+
+// feedbackURL links to a new issue about the comment, so that students can
+// tell us when it wasn't helpful.
+func feedbackURL(data footerData) string {
+	about := "rikki-"
+	if data.Smell != "" {
+		about = data.Track + "/" + data.Smell
+		if data.Variant != "" {
+			about += "/" + data.Variant
+		}
+	}
+	q := neturl.Values{}
+	q.Set("title", fmt.Sprintf("Unhelpful comment: %s", about))
+	q.Set("body", fmt.Sprintf("Submission: %s\n\nWhat was unhelpful about the comment?\n", data.UUID))
+	return issuesURL + "?" + q.Encode()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFooter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rikki-footer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if f, err := loadFooter(dir); err != nil || f != nil {
+		t.Fatalf("without a footer.md - got: %v, %v, want no footer", f, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "footer"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	footers := map[string]string{
+		"footer.md":         "_Robot review of {{.Smell}}._\n",
+		"footer/crystal.md": "_Robot review, [feedback]({{.FeedbackURL}})._\n",
+		"footer/ruby.md":    "{{/* no footer for ruby */}}\n",
+	}
+	for name, s := range footers {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := loadFooter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		track, want string
+	}{
+		{"go", "Run gofmt.\n-----\n_Robot review of gofmt._"},
+		{"crystal", "Run gofmt.\n-----\n_Robot review, [feedback](https://github.com/exercism/rikki/issues/new?"},
+		{"ruby", "Run gofmt."},
+	}
+	for _, test := range tests {
+		b, err := f.sign([]byte("Run gofmt."), footerData{Track: test.track, Smell: "gofmt", UUID: "abc"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(b), test.want) {
+			t.Errorf("%s - got: %q, want: %q", test.track, b, test.want)
+		}
+	}
+}
+
+func TestFeedbackURL(t *testing.T) {
+	got := feedbackURL(footerData{Track: "go", Smell: "gofmt", Variant: "b", UUID: "abc"})
+	want := "https://github.com/exercism/rikki/issues/new?body=Submission%3A+abc%0A%0AWhat+was+unhelpful+about+the+comment%3F%0A&title=Unhelpful+comment%3A+go%2Fgofmt%2Fb"
+	if got != want {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+}
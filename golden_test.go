@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/exercism/rikki/analysis/crystal"
+	"github.com/exercism/rikki/analysis/crystal/crystaltest"
+	"github.com/exercism/rikki/analysis/ruby"
+	"github.com/exercism/rikki/analysis/ruby/rubytest"
+	"github.com/exercism/rikki/exercismtest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current results")
+
+// goldenCase is a fixture in testdata/golden/<track>/<slug>/<case>:
+//
+//	solution/        the files of the submission
+//	analyzer.json    what the stub ruby- or crystal-analyzer responds, if the track has one
+//	comments/        comments to choose from, instead of the real ones (optional)
+//	smells.golden    the smells we expect to detect, sorted, one per line
+//	comment.golden   the smell we expect to comment on, or nothing
+//
+// The ruby analyzer shuffles smells, so a ruby case should only detect
+// one smell that has a comment.
+type goldenCase struct {
+	dir, track, slug string
+}
+
+func goldenCases(t *testing.T) []goldenCase {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "golden", "*", "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []goldenCase
+	for _, dir := range dirs {
+		parts := strings.Split(filepath.ToSlash(dir), "/")
+		cases = append(cases, goldenCase{dir: dir, track: parts[2], slug: parts[3]})
+	}
+	return cases
+}
+
+func (c goldenCase) name() string {
+	return strings.TrimPrefix(filepath.ToSlash(c.dir), "testdata/golden/")
+}
+
+func (c goldenCase) files(t *testing.T) map[string]string {
+	dir := filepath.Join(c.dir, "solution")
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = string(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// stubAnalyzer points the track's analyzer at a stub that gives the canned
+// response. It returns a function that stops the stub.
+func (c goldenCase) stubAnalyzer(t *testing.T) func() {
+	b, err := ioutil.ReadFile(filepath.Join(c.dir, "analyzer.json"))
+	if os.IsNotExist(err) {
+		return func() {}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch c.track {
+	case "ruby":
+		var results []rubytest.Result
+		if err := json.Unmarshal(b, &results); err != nil {
+			t.Fatalf("%s: %s", c.name(), err)
+		}
+		ts := rubytest.NewServer()
+		ts.Respond(results...)
+		ruby.Host = ts.URL
+		return ts.Close
+	case "crystal":
+		var problems []crystaltest.Problem
+		if err := json.Unmarshal(b, &problems); err != nil {
+			t.Fatalf("%s: %s", c.name(), err)
+		}
+		ts := crystaltest.NewServer()
+		ts.Respond(problems...)
+		crystal.Host = ts.URL
+		return ts.Close
+	}
+	t.Fatalf("%s: the %s track has no stub analyzer", c.name(), c.track)
+	return nil
+}
+
+// run analyzes the solution, and reports the smells it detected and the
+// smell it chose to comment on. It also puts the solution through the
+// analyzer job, to check that the comment it posts is the one chosen.
+func (c goldenCase) run(t *testing.T) (smells []string, comment string) {
+	defer c.stubAnalyzer(t)()
+
+	api := exercismtest.NewServer()
+	defer api.Close()
+	files := c.files(t)
+	api.AddSubmission("golden", exercismtest.Submission{TrackID: c.track, Slug: c.slug, Files: files})
+
+	dir := filepath.Join(c.dir, "comments")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		dir = "comments"
+	}
+	analyzer, err := NewAnalyzer(NewExercism(api.URL, SharedKey{Key: "key"}, nil), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solution := &Solution{UUID: "golden", TrackID: c.track, Slug: c.slug, Files: files}
+	rev, err := analyzer.analyze(analyzers[c.track], solution)
+	if err != nil {
+		t.Fatalf("%s: %s", c.name(), err)
+	}
+
+	analyzer.process(newTestMsg(t, "analyze", "golden"))
+	posted := api.Comments()
+	switch {
+	case len(rev.comment) == 0 && len(posted) > 0:
+		t.Errorf("%s: expected no comment, got %q", c.name(), posted[0].Body)
+	case len(rev.comment) > 0:
+		want, err := analyzer.footer.sign(rev.comment, footerData{Track: c.track, Smell: rev.smell, Variant: rev.variant, UUID: "golden"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posted) != 1 || posted[0].Body != string(want) {
+			t.Errorf("%s: expected the %s comment to be posted, got %d comments", c.name(), rev.smell, len(posted))
+		}
+	}
+
+	smells = append([]string(nil), rev.smells...)
+	sort.Strings(smells)
+	return smells, rev.smell
+}
+
+func TestGolden(t *testing.T) {
+	cases := goldenCases(t)
+	if len(cases) == 0 {
+		t.Fatal("no golden cases in testdata/golden")
+	}
+
+	for _, c := range cases {
+		smells, comment := c.run(t)
+
+		got := map[string]string{
+			"smells.golden":  lines(smells),
+			"comment.golden": lines([]string{comment}),
+		}
+		for name, s := range got {
+			path := filepath.Join(c.dir, name)
+			if *update {
+				if err := ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			want, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Errorf("%s: %s (run go test -update to create it)", c.name(), err)
+				continue
+			}
+			if s != string(want) {
+				t.Errorf("%s: %s has changed\n%s", c.name(), name, diff(string(want), s))
+			}
+		}
+	}
+}
+
+// lines writes one value per line, skipping empty ones.
+func lines(values []string) string {
+	var buf bytes.Buffer
+	for _, v := range values {
+		if v != "" {
+			fmt.Fprintln(&buf, v)
+		}
+	}
+	return buf.String()
+}
+
+// diff shows which lines were expected (-) and which we got instead (+).
+// The golden files are short sorted lists, so a line-by-line
+// comparison is all we need.
+func diff(want, got string) string {
+	w := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	g := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	in := func(s string, list []string) bool {
+		for _, v := range list {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+
+	var buf bytes.Buffer
+	for _, s := range w {
+		if s != "" && !in(s, g) {
+			fmt.Fprintf(&buf, "- %s\n", s)
+		}
+	}
+	for _, s := range g {
+		if s != "" && !in(s, w) {
+			fmt.Fprintf(&buf, "+ %s\n", s)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// checkTimeout bounds how long a single readiness check may take.
+const checkTimeout = 2 * time.Second
+
+// check is one dependency that has to be in order before rikki- is ready.
+type check struct {
+	name string
+	fn   func() error
+}
+
+// healthz reports that the process is alive and serving requests.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz runs every check and reports the result of each.
+// It responds with 503 if any of them fail.
+func readyz(checks []check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		results := make(map[string]string, len(checks))
+		ready := true
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, c := range checks {
+			wg.Add(1)
+			go func(c check) {
+				defer wg.Done()
+				result := "ok"
+				if err := c.fn(); err != nil {
+					result = err.Error()
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				results[c.name] = result
+				ready = ready && result == "ok"
+			}(c)
+		}
+		wg.Wait()
+
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(struct {
+			Ready  bool              `json:"ready"`
+			Checks map[string]string `json:"checks"`
+		}{ready, results})
+	}
+}
+
+// redisCheck pings redis through the worker connection pool.
+// Neither getting a connection nor the ping have a deadline of their own,
+// so the check gives up on them after checkTimeout.
+func redisCheck(pool *redis.Pool) func() error {
+	return func() error {
+		done := make(chan error, 1)
+		go func() {
+			conn := pool.Get()
+			defer conn.Close()
+			_, err := conn.Do("PING")
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(checkTimeout):
+			return fmt.Errorf("redis didn't answer within %s", checkTimeout)
+		}
+	}
+}
+
+// commentsCheck verifies that the comment library has been loaded.
+func commentsCheck(analyzer *Analyzer) func() error {
+	return func() error {
+		if len(analyzer.comments) == 0 {
+			return fmt.Errorf("no comments loaded")
+		}
+		return nil
+	}
+}
+
+// reachableCheck verifies that something answers HTTP requests at the url.
+// Any response counts; we only care that the service is up.
+func reachableCheck(url string) func() error {
+	client := &http.Client{Timeout: checkTimeout}
+	return func() error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
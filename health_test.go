@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestReadyz(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer up.Close()
+
+	hang := make(chan struct{})
+	defer close(hang)
+	hung := &redis.Pool{Dial: func() (redis.Conn, error) {
+		<-hang
+		return nil, errors.New("gave up")
+	}}
+
+	tests := []struct {
+		desc   string
+		checks []check
+		status int
+	}{
+		{
+			"all good",
+			[]check{{"exercism", reachableCheck(up.URL)}},
+			http.StatusOK,
+		},
+		{
+			"one failing",
+			[]check{
+				{"exercism", reachableCheck(up.URL)},
+				{"redis", func() error { return errors.New("connection refused") }},
+			},
+			http.StatusServiceUnavailable,
+		},
+		{
+			"redis hangs",
+			[]check{{"redis", redisCheck(hung)}},
+			http.StatusServiceUnavailable,
+		},
+		{
+			"no comments",
+			[]check{{"comments", commentsCheck(&Analyzer{})}},
+			http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		readyz(test.checks).ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+		if rec.Code != test.status {
+			t.Errorf("%s: status - got: %d, want: %d", test.desc, rec.Code, test.status)
+		}
+
+		var body struct {
+			Checks map[string]string `json:"checks"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Checks) != len(test.checks) {
+			t.Errorf("%s: got %d check results, want %d", test.desc, len(body.Checks), len(test.checks))
+		}
+	}
+}
@@ -1,44 +1,200 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/exercism/rikki/analysis"
 	"github.com/jrallison/go-workers"
+	"github.com/sirupsen/logrus"
 )
 
+// almostThere is the message for a hello world that doesn't work yet.
+const almostThere = "almost-there"
+
 // Hello is a job that provides encouragement after someone submits "Hello World".
 // The job receives the uuid of a submission and submits a comment from rikki-
 // to the conversation on exercism.
+//
+// The welcome job does the same for the first iteration of any exercise,
+// choosing the most specific message in the hello directory:
+// hello/<track>/<slug>.md, then hello/<track>.md, then hello/hello.md.
+// Hello world submissions with blocking problems get hello/almost-there.md.
 type Hello struct {
 	exercism *Exercism
-	comment  []byte
+	messages map[string][]byte
+	footer   *footer
+	limits   *commentLimiter
+	cache    *analysisCache
+	limiter  *trackLimiter
 }
 
 // NewHello configures a Hello job to talk to the exercism API.
 func NewHello(exercism *Exercism, dir string) (*Hello, error) {
-	b, err := read(fmt.Sprintf("%s/hello/hello.md", dir))
+	messages, err := loadMessages(filepath.Join(dir, "hello"))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"hello", almostThere} {
+		if _, ok := messages[name]; !ok {
+			return nil, fmt.Errorf("no %s message in %s", name, filepath.Join(dir, "hello", name+".md"))
+		}
+	}
+	footer, err := loadFooter(dir)
 	if err != nil {
 		return nil, err
 	}
 	return &Hello{
 		exercism: exercism,
-		comment:  b,
+		messages: messages,
+		footer:   footer,
 	}, nil
 }
 
+// loadMessages reads every message in dir, keyed by its path without the
+// extension, e.g. "go/hello-world" for dir/go/hello-world.md.
+func loadMessages(dir string) (map[string][]byte, error) {
+	messages := map[string][]byte{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".md" {
+			return err
+		}
+		b, err := read(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		messages[strings.TrimSuffix(filepath.ToSlash(rel), ".md")] = b
+		return nil
+	})
+	return messages, err
+}
+
+// message chooses the most specific message for the exercise, and reports
+// which one it chose.
+func (hello *Hello) message(track, slug string) (string, []byte) {
+	for _, name := range []string{track + "/" + slug, track, "hello"} {
+		if b, ok := hello.messages[name]; ok {
+			return name, b
+		}
+	}
+	return "", nil
+}
+
 func (hello *Hello) process(msg *workers.Msg) {
+	hello.greet(msg, "hello")
+}
+
+// welcome greets the first iteration of any exercise.
+func (hello *Hello) welcome(msg *workers.Msg) {
+	hello.greet(msg, "welcome")
+}
+
+// greet posts a hello, or a welcome, on the first iteration of a solution.
+// A hello world that doesn't work yet gets the "almost there" message
+// instead of congratulations.
+func (hello *Hello) greet(msg *workers.Msg, kind string) {
+	job := &outcome{queue: queueOf(msg), result: "error"}
+	defer job.record()
+	log := jobLogger(msg)
+
 	args := msg.Args()
 	uuid, err := args.GetIndex(0).String()
 	if err != nil {
-		lgr.Printf("unable to determine submission uuid - %s\n", err)
+		log.WithError(err).Error("unable to determine submission uuid")
 		return
 	}
+	log = log.WithField("uuid", uuid)
 
 	if args.GetIndex(1).MustInt(1) > 1 {
+		job.result = "skipped"
+		return
+	}
+
+	if !hello.limits.wait(msg, job, log) {
+		return
+	}
+
+	solution, err := hello.exercism.FetchSolution(context.Background(), uuid)
+	if err != nil {
+		log.WithError(err).Error("unable to fetch solution")
+		job.retry(msg, err)
+		return
+	}
+	job.track = solution.TrackID
+	log = log.WithFields(logrus.Fields{"track": solution.TrackID, "slug": solution.Slug})
+
+	name, comment := "hello", hello.messages["hello"]
+	if kind == "welcome" {
+		name, comment = hello.message(solution.TrackID, solution.Slug)
+	} else {
+		blocked, err := hello.blocked(solution, log)
+		if err == errTrackBusy {
+			if err := requeue(msg, requeueDelay); err != nil {
+				log.WithError(err).Error("unable to defer job")
+				return
+			}
+			log.Info("deferred - track is at its concurrency limit")
+			job.result = "deferred"
+			return
+		}
+		if blocked {
+			name, comment = almostThere, hello.messages[almostThere]
+		}
+	}
+	log = log.WithField("message", name)
+
+	if !hello.limits.admit(msg, job, log, solution.Username, solution.TrackID) {
 		return
 	}
 
-	if err := hello.exercism.SubmitComment(hello.comment, uuid); err != nil {
-		lgr.Printf("%s\n", err)
+	comment, err = hello.footer.sign(comment, footerData{Track: solution.TrackID, UUID: uuid})
+	if err != nil {
+		log.WithError(err).Error("unable to sign comment")
+		return
+	}
+	if err := hello.exercism.SubmitComment(context.Background(), comment, uuid); err != nil {
+		log.WithError(err).Error("unable to submit comment")
+		job.retry(msg, err)
+		return
+	}
+	log.Info("hello submitted")
+	commentsPosted.WithLabelValues(solution.TrackID, name, "").Inc()
+	job.result = "commented"
+}
+
+// blocked runs the track's analyzer over the solution, and reports whether
+// it found anything that stops the code from working, or from being
+// reviewed. It goes through the cache and the track's slots, the same as
+// the analyze job, and so fails with errTrackBusy when the track is busy.
+//
+// The go analyzer runs locally, so when it fails it's because the code
+// doesn't parse. The remote analyzers fail when they're unavailable, which
+// is no reason to hold back congratulations.
+func (hello *Hello) blocked(solution *Solution, log *logrus.Entry) (bool, error) {
+	fn, ok := analyzers[solution.TrackID]
+	if !ok {
+		return false, nil
+	}
+	smells, _, err := detect(hello.cache, hello.limiter, fn, solution)
+	if err == errTrackBusy {
+		return false, err
+	}
+	if err != nil {
+		log.WithError(err).Info("analysis failed")
+		return solution.TrackID == "go", nil
+	}
+	for _, key := range smells {
+		if smell, ok := catalogs[solution.TrackID].Lookup(key); ok && smell.Severity == analysis.Blocking {
+			log.WithField("smell", key).Info("blocking smell detected")
+			return true, nil
+		}
 	}
+	return false, nil
 }
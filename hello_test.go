@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/exercism/rikki/exercismtest"
+)
+
+func TestHelloProcess(t *testing.T) {
+	hello, err := ioutil.ReadFile("comments/hello/hello.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	almost, err := ioutil.ReadFile("comments/hello/almost-there.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	working := map[string]string{"hello_world.go": "package greeting\n\n// HelloWorld says hello.\nfunc HelloWorld() string {\n\treturn \"Hello, World!\"\n}\n"}
+	broken := map[string]string{"hello_world.go": "package greeting\n\nfunc HelloWorld() string {\n\treturn \"Hello, World!\"\n"}
+	unformatted := map[string]string{"hello_world.go": "package greeting\n\n// HelloWorld says hello.\nfunc HelloWorld() string {\n  return \"Hello, World!\"\n}\n"}
+	vetted := map[string]string{"hello_world.go": "package greeting\n\nimport \"fmt\"\n\n// HelloWorld says hello.\nfunc HelloWorld() string {\n\treturn fmt.Sprintf(\"Hello, %s!\")\n}\n"}
+
+	tests := []struct {
+		desc      string
+		files     map[string]string
+		iteration int
+		comment   []byte
+	}{
+		{"first iteration", working, 1, hello},
+		{"later iteration", working, 2, nil},
+		{"doesn't parse", broken, 1, almost},
+		{"not gofmt-formatted", unformatted, 1, hello},
+		{"go vet fails", vetted, 1, almost},
+	}
+
+	for _, test := range tests {
+		api := exercismtest.NewServer()
+		api.AddSubmission("abc", exercismtest.Submission{TrackID: "go", Slug: "hello-world", Files: test.files})
+
+		job, err := NewHello(NewExercism(api.URL, SharedKey{Key: "key"}, nil), "comments")
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.process(newTestMsg(t, "hello", "abc", test.iteration))
+		comments := api.Comments()
+		api.Close()
+
+		want := 0
+		if test.comment != nil {
+			want = 1
+		}
+		if len(comments) != want {
+			t.Errorf("%s: got %d comments, want %d", test.desc, len(comments), want)
+			continue
+		}
+		if want > 0 && !bytes.HasPrefix([]byte(comments[0].Body), test.comment) {
+			t.Errorf("%s: unexpected comment %q", test.desc, comments[0].Body)
+		}
+	}
+}
+
+func TestHelloAnalyzesThroughCacheAndLimiter(t *testing.T) {
+	api := exercismtest.NewServer()
+	defer api.Close()
+	files := map[string]string{"hello_world.go": "package greeting\n\n// HelloWorld says hello.\nfunc HelloWorld() string {\n\treturn \"Hello, World!\"\n}\n"}
+	api.AddSubmission("abc", exercismtest.Submission{TrackID: "go", Slug: "hello-world", Files: files})
+	api.AddSubmission("def", exercismtest.Submission{TrackID: "go", Slug: "hello-world", Files: files})
+
+	job, err := NewHello(NewExercism(api.URL, SharedKey{Key: "key"}, nil), "comments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.cache = newAnalysisCache(10, time.Hour)
+	job.limiter = newTrackLimiter(map[string]TrackConfig{"go": {Concurrency: 1}})
+
+	// The track is busy, so the job is put back on the queue.
+	job.limiter.acquire("go")
+	job.process(newTestMsg(t, "hello", "abc", 1))
+	if n := len(api.Comments()); n != 0 {
+		t.Errorf("busy track - got %d comments, want 0", n)
+	}
+	job.limiter.release("go")
+
+	job.process(newTestMsg(t, "hello", "abc", 1))
+	if !job.cache.contains(&Solution{TrackID: "go", Slug: "hello-world", Files: files}) {
+		t.Error("the analysis should have been cached")
+	}
+
+	// Identical code is congratulated from the cache, without a slot.
+	job.limiter.acquire("go")
+	job.process(newTestMsg(t, "hello", "def", 1))
+	if n := len(api.Comments()); n != 2 {
+		t.Errorf("got %d comments, want 2", n)
+	}
+}
+
+func TestWelcomeProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rikki-hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for path, s := range map[string]string{
+		"hello/hello.md":             "Welcome!",
+		"hello/almost-there.md":      "Almost there!",
+		"hello/go.md":                "Welcome to Go!",
+		"hello/go/hello-world.md":    "Hello, Gopher!",
+		"hello/ruby/hello-world.txt": "Not a message.",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		desc      string
+		track     string
+		slug      string
+		iteration int
+		comment   string
+	}{
+		{"exercise message", "go", "hello-world", 1, "Hello, Gopher!"},
+		{"track message", "go", "leap", 1, "Welcome to Go!"},
+		{"generic message", "ruby", "hello-world", 1, "Welcome!"},
+		{"later iteration", "go", "leap", 2, ""},
+	}
+
+	for _, test := range tests {
+		api := exercismtest.NewServer()
+		api.AddSubmission("abc", exercismtest.Submission{TrackID: test.track, Slug: test.slug})
+
+		job, err := NewHello(NewExercism(api.URL, SharedKey{Key: "key"}, nil), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.welcome(newTestMsg(t, "welcome", "abc", test.iteration))
+		comments := api.Comments()
+		api.Close()
+
+		var got string
+		if len(comments) > 0 {
+			got = comments[0].Body
+		}
+		if got != test.comment {
+			t.Errorf("%s - got: %q, want: %q", test.desc, got, test.comment)
+		}
+	}
+}
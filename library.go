@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultLocale is the language comments are written in first.
+// Comments without a locale suffix are in the default locale.
+const defaultLocale = "en"
+
+var (
+	// rgxLocale matches the locale suffix of a translated comment, e.g. gofmt.es or gofmt.pt-BR.
+	rgxLocale = regexp.MustCompile(`^(.+)\.([a-z]{2}(?:-[A-Z]{2})?)$`)
+	// rgxVariant matches a variant of a comment, e.g. gofmt/a.
+	rgxVariant = regexp.MustCompile(`^(.+)/([a-z])$`)
+)
+
+// library holds the comments for each track and smell: every variant of the
+// comment, in every locale it has been translated to.
+type library map[string]map[string]variants
+
+// variants are the alternative comments about a smell, by name.
+// A smell with a single comment has one variant, named "".
+type variants map[string]translations
+
+// translations are the same comment in different locales.
+type translations map[string][]byte
+
+// loadComments reads the comments for each smell, by track.
+//
+// The comment for a smell is in <dir>/<track>/<smell>.md, and its
+// translations are next to it, as <smell>.<locale>.md.
+// A smell can have several variants instead, as <smell>/<letter>.md,
+// so we can see which one works best.
+func loadComments(dir string) (library, error) {
+	comments := make(library)
+
+	fn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		b, err := read(path)
+		if err != nil {
+			return err
+		}
+		trackID, smell := identifyComment(dir, path)
+		smell, locale := splitLocale(smell)
+		smell, variant := splitVariant(smell)
+		comments.add(trackID, smell, variant, locale, b)
+
+		return nil
+	}
+
+	if err := filepath.Walk(dir, fn); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// splitLocale takes the locale suffix off a smell, if it has one.
+func splitLocale(name string) (smell, locale string) {
+	m := rgxLocale.FindStringSubmatch(name)
+	if m == nil {
+		return name, defaultLocale
+	}
+	return m[1], m[2]
+}
+
+// splitVariant takes the variant off a smell, if it has one.
+func splitVariant(name string) (smell, variant string) {
+	m := rgxVariant.FindStringSubmatch(filepath.ToSlash(name))
+	if m == nil {
+		return name, ""
+	}
+	return m[1], m[2]
+}
+
+func (l library) add(track, smell, variant, locale string, b []byte) {
+	if l[track] == nil {
+		l[track] = make(map[string]variants)
+	}
+	if l[track][smell] == nil {
+		l[track][smell] = make(variants)
+	}
+	if l[track][smell][variant] == nil {
+		l[track][smell][variant] = make(translations)
+	}
+	l[track][smell][variant][locale] = b
+}
+
+// comment finds the comment for a smell, and tells which variant and locale
+// it found.
+//
+// The variant is chosen by the uuid of the submission, so the same
+// submission always gets the same one. The locale falls back from a regional
+// locale such as pt-BR to its language, pt, and then to the default locale.
+func (l library) comment(track, smell, uuid, locale string) (b []byte, variant, found string) {
+	vs := l[track][smell]
+	if len(vs) == 0 {
+		return nil, "", ""
+	}
+	variant = vs.choose(uuid)
+	for _, candidate := range fallbacks(locale) {
+		if b := vs[variant][candidate]; len(b) > 0 {
+			return b, variant, candidate
+		}
+	}
+	return nil, "", ""
+}
+
+// choose picks a variant for a submission.
+func (vs variants) choose(uuid string) string {
+	names := make([]string, 0, len(vs))
+	for name := range vs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sum := sha256.Sum256([]byte(uuid))
+	return names[binary.BigEndian.Uint32(sum[:4])%uint32(len(names))]
+}
+
+func fallbacks(locale string) []string {
+	var locales []string
+	if locale != "" {
+		locales = append(locales, locale)
+	}
+	if i := strings.Index(locale, "-"); i > 0 {
+		locales = append(locales, locale[:i])
+	}
+	return append(locales, defaultLocale)
+}
@@ -0,0 +1,99 @@
+package main
+
+import "testing"
+
+func TestSplitLocale(t *testing.T) {
+	tests := []struct {
+		name, smell, locale string
+	}{
+		{"gofmt", "gofmt", "en"},
+		{"gofmt.es", "gofmt", "es"},
+		{"gofmt.pt-BR", "gofmt", "pt-BR"},
+		{"loops/nesting.fr", "loops/nesting", "fr"},
+		{"indentation/two_spaces", "indentation/two_spaces", "en"},
+	}
+
+	for _, test := range tests {
+		smell, locale := splitLocale(test.name)
+		if smell != test.smell || locale != test.locale {
+			t.Errorf("%s - got: %s, %s, want: %s, %s", test.name, smell, locale, test.smell, test.locale)
+		}
+	}
+}
+
+func TestLibraryComment(t *testing.T) {
+	l := library{}
+	l.add("go", "gofmt", "", "en", []byte("Run gofmt."))
+	l.add("go", "gofmt", "", "es", []byte("Usa gofmt."))
+	l.add("go", "gofmt", "", "pt-BR", []byte("Use o gofmt."))
+
+	tests := []struct {
+		locale, comment, found string
+	}{
+		{"", "Run gofmt.", "en"},
+		{"es", "Usa gofmt.", "es"},
+		{"es-MX", "Usa gofmt.", "es"},
+		{"pt-BR", "Use o gofmt.", "pt-BR"},
+		{"pt", "Run gofmt.", "en"},
+		{"de", "Run gofmt.", "en"},
+	}
+
+	for _, test := range tests {
+		b, _, found := l.comment("go", "gofmt", "abc", test.locale)
+		if string(b) != test.comment || found != test.found {
+			t.Errorf("%q - got: %s (%s), want: %s (%s)", test.locale, b, found, test.comment, test.found)
+		}
+	}
+
+	if b, _, _ := l.comment("go", "go-vet", "abc", "es"); b != nil {
+		t.Errorf("got a comment for a smell without one: %s", b)
+	}
+}
+
+func TestSplitVariant(t *testing.T) {
+	tests := []struct {
+		name, smell, variant string
+	}{
+		{"gofmt", "gofmt", ""},
+		{"gofmt/a", "gofmt", "a"},
+		{"indentation/tab", "indentation/tab", ""},
+		{"indentation/tab/b", "indentation/tab", "b"},
+	}
+
+	for _, test := range tests {
+		smell, variant := splitVariant(test.name)
+		if smell != test.smell || variant != test.variant {
+			t.Errorf("%s - got: %s, %s, want: %s, %s", test.name, smell, variant, test.smell, test.variant)
+		}
+	}
+}
+
+func TestLibraryVariants(t *testing.T) {
+	l := library{}
+	l.add("go", "gofmt", "a", "en", []byte("Run gofmt."))
+	l.add("go", "gofmt", "a", "es", []byte("Usa gofmt."))
+	l.add("go", "gofmt", "b", "en", []byte("Format your code."))
+
+	seen := map[string]bool{}
+	for _, uuid := range []string{"a1", "b2", "c3", "d4", "e5", "f6", "g7", "h8"} {
+		b, variant, _ := l.comment("go", "gofmt", uuid, "")
+		seen[variant] = true
+
+		again, _, _ := l.comment("go", "gofmt", uuid, "")
+		if string(again) != string(b) {
+			t.Errorf("%s - got: %s, then %s; a submission should always get the same variant", uuid, b, again)
+		}
+
+		// An untranslated variant falls back to English, rather than switching variants.
+		es, esVariant, locale := l.comment("go", "gofmt", uuid, "es")
+		if esVariant != variant {
+			t.Errorf("%s - got variant %s in spanish, want: %s", uuid, esVariant, variant)
+		}
+		if variant == "b" && (locale != "en" || string(es) != "Format your code.") {
+			t.Errorf("%s - got: %s (%s), want the english comment", uuid, es, locale)
+		}
+	}
+	if !seen["a"] || !seen["b"] {
+		t.Errorf("expected submissions to be spread across variants, got %v", seen)
+	}
+}
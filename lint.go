@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/exercism/rikki/analysis"
+)
+
+// rgxLink matches inline markdown links, capturing the text and the target.
+var rgxLink = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)\)`)
+
+// lintProblem is something wrong with the comment library.
+type lintProblem struct {
+	path string
+	msg  string
+}
+
+func (p lintProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.path, p.msg)
+}
+
+// lintComments checks the comments in dir against the catalog of smells that
+// each track's analyzer can detect. It reports smells without a comment, comments
+// that no analyzer will ever ask for, and comments that aren't fit to post.
+// With client set, it also checks that every link in a comment resolves.
+func lintComments(dir string, catalogs map[string]analysis.Catalog, client *http.Client) ([]lintProblem, error) {
+	var problems []lintProblem
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if filepath.Ext(path) != ".md" {
+			problems = append(problems, lintProblem{path, "not a markdown file"})
+			return nil
+		}
+		b, err := read(path)
+		if err != nil {
+			return err
+		}
+		if isFooter(dir, path) {
+			if b, err = renderFooter(path); err != nil {
+				problems = append(problems, lintProblem{path, err.Error()})
+				return nil
+			}
+		}
+		for _, msg := range lintMarkdown(string(b), client) {
+			problems = append(problems, lintProblem{path, msg})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	analyzerDir := filepath.Join(dir, "analyzer")
+	comments, err := loadComments(analyzerDir)
+	if err != nil {
+		return nil, err
+	}
+	for track, catalog := range catalogs {
+		for _, smell := range catalog.Keys() {
+			if len(comments[track][smell]) == 0 {
+				path := filepath.Join(analyzerDir, track, smell+".md")
+				problems = append(problems, lintProblem{path, "missing comment for a detectable smell"})
+			}
+		}
+	}
+	for track, bySmell := range comments {
+		for smell, vs := range bySmell {
+			if _, ok := vs[""]; ok && len(vs) > 1 {
+				path := filepath.Join(analyzerDir, track, smell+".md")
+				problems = append(problems, lintProblem{path, "smell has both a single comment and variants"})
+			}
+			for variant, byLocale := range vs {
+				name := smell
+				if variant != "" {
+					name = smell + "/" + variant
+				}
+				for locale := range byLocale {
+					path := filepath.Join(analyzerDir, track, name+".md")
+					if locale != defaultLocale {
+						path = filepath.Join(analyzerDir, track, name+"."+locale+".md")
+						if _, ok := byLocale[defaultLocale]; !ok {
+							problems = append(problems, lintProblem{path, "translation of a comment that doesn't exist"})
+							continue
+						}
+					}
+					if _, ok := catalogs[track].Lookup(smell); !ok {
+						problems = append(problems, lintProblem{path, "orphan - no analyzer detects this smell"})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		if problems[i].path != problems[j].path {
+			return problems[i].path < problems[j].path
+		}
+		return problems[i].msg < problems[j].msg
+	})
+	return problems, nil
+}
+
+func isFooter(dir, path string) bool {
+	return path == filepath.Join(dir, "footer.md") || filepath.Dir(path) == filepath.Join(dir, "footer")
+}
+
+// renderFooter fills in a footer template with an example comment,
+// so that it can be checked like any other.
+func renderFooter(path string) ([]byte, error) {
+	t, err := parseFooter(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &footer{base: t}
+	return f.sign(nil, footerData{Track: "go", Smell: "gofmt", Variant: "a", UUID: "abc"})
+}
+
+// lintMarkdown reports what's wrong with a comment: it must say something,
+// close its code blocks, and only link to absolute http(s) URLs, since it is
+// posted somewhere else entirely.
+func lintMarkdown(s string, client *http.Client) []string {
+	if strings.TrimSpace(s) == "" {
+		return []string{"comment is empty"}
+	}
+
+	var msgs []string
+	var prose []string
+	fenced := false
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if !fenced {
+			prose = append(prose, line)
+		}
+	}
+	if fenced {
+		msgs = append(msgs, "unclosed code block")
+	}
+
+	for _, m := range rgxLink.FindAllStringSubmatch(strings.Join(prose, "\n"), -1) {
+		text, target := m[1], strings.TrimSpace(m[2])
+		if strings.TrimSpace(text) == "" {
+			msgs = append(msgs, fmt.Sprintf("link to %q has no text", target))
+		}
+		u, err := neturl.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			msgs = append(msgs, fmt.Sprintf("link %q is not an absolute http(s) URL", target))
+			continue
+		}
+		if client != nil {
+			if err := checkLink(client, target); err != nil {
+				msgs = append(msgs, fmt.Sprintf("link %q is broken - %s", target, err))
+			}
+		}
+	}
+	return msgs
+}
+
+func checkLink(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/exercism/rikki/analysis"
+)
+
+func TestLintMarkdown(t *testing.T) {
+	tests := []struct {
+		desc string
+		md   string
+		msgs []string
+	}{
+		{"fine", "Use [gofmt](https://blog.golang.org/go-fmt-your-code).\n", nil},
+		{"empty", " \n\n", []string{"comment is empty"}},
+		{"unclosed code block", "Try:\n\n```go\nx := 1\n", []string{"unclosed code block"}},
+		{"links in code are ignored", "```\n[a](b)\n```\n", nil},
+		{"relative link", "See [the docs](docs/gofmt.md).", []string{`link "docs/gofmt.md" is not an absolute http(s) URL`}},
+		{"no text", "See [](http://exercism.io).", []string{`link to "http://exercism.io" has no text`}},
+		{"empty target", "See [here]().", []string{`link "" is not an absolute http(s) URL`}},
+	}
+
+	for _, test := range tests {
+		msgs := lintMarkdown(test.md, nil)
+		if !reflect.DeepEqual(msgs, test.msgs) {
+			t.Errorf("%s - got: %q, want: %q", test.desc, msgs, test.msgs)
+		}
+	}
+}
+
+func TestLintMarkdownOnline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	md := "[ok](" + ts.URL + "/ok) and [gone](" + ts.URL + "/gone)"
+	msgs := lintMarkdown(md, http.DefaultClient)
+	want := []string{`link "` + ts.URL + `/gone" is broken - status 404`}
+	if !reflect.DeepEqual(msgs, want) {
+		t.Errorf("got: %q, want: %q", msgs, want)
+	}
+}
+
+func TestLintComments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rikki-comments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(path, s string) {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("hello/hello.md", "Hello!")
+	write("analyzer/go/gofmt.md", "Run gofmt.")
+	write("analyzer/go/retired.md", "Nobody asks for this.")
+	write("analyzer/go/gofmt.es.md", "Usa gofmt.")
+	write("analyzer/go/stub.fr.md", "Supprimez les commentaires.")
+	write("analyzer/go/go-vet/a.md", "Run go vet.")
+	write("analyzer/go/go-vet/b.md", "Vet your code.")
+	write("analyzer/go/go-vet/b.es.md", "Usa go vet.")
+	write("analyzer/go/gofmt/a.md", "Format your code.")
+	write("analyzer/ruby/loops/nesting.md", "")
+
+	catalogs := map[string]analysis.Catalog{
+		"go":   {{Key: "gofmt"}, {Key: "go-vet"}},
+		"ruby": {{Key: "loops/nesting"}},
+	}
+	problems, err := lintComments(dir, catalogs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range problems {
+		rel, err := filepath.Rel(dir, p.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, filepath.ToSlash(rel)+": "+p.msg)
+	}
+	want := []string{
+		"analyzer/go/gofmt.md: smell has both a single comment and variants",
+		"analyzer/go/retired.md: orphan - no analyzer detects this smell",
+		"analyzer/go/stub.fr.md: translation of a comment that doesn't exist",
+		"analyzer/ruby/loops/nesting.md: comment is empty",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestLintCommentsLibrary(t *testing.T) {
+	problems, err := lintComments("comments", catalogs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Error(p)
+	}
+}
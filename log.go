@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jrallison/go-workers"
+	"github.com/sirupsen/logrus"
+)
+
+// lgr is rikki-'s logger. Each line is a JSON object, so that the path of a
+// single submission can be followed through fetch, analyze and comment.
+var lgr = logrus.New()
+
+func init() {
+	lgr.Out = os.Stdout
+	lgr.Formatter = &logrus.JSONFormatter{}
+}
+
+// configureLogger applies the log level and format from the configuration.
+func configureLogger(lc LogConfig) error {
+	level, err := logrus.ParseLevel(lc.Level)
+	if err != nil {
+		return err
+	}
+	lgr.Level = level
+
+	switch lc.Format {
+	case "json":
+		lgr.Formatter = &logrus.JSONFormatter{}
+	case "text":
+		lgr.Formatter = &logrus.TextFormatter{}
+	default:
+		return fmt.Errorf("unknown log format %q", lc.Format)
+	}
+	return nil
+}
+
+// jobLogger returns a logger that tags every line with the job it belongs to.
+// Callers add the submission uuid, track and slug as they learn them.
+func jobLogger(msg *workers.Msg) *logrus.Entry {
+	return lgr.WithFields(logrus.Fields{
+		"jid":   msg.Jid(),
+		"queue": queueOf(msg),
+	})
+}
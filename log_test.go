@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/jrallison/go-workers"
+)
+
+func TestJobLogger(t *testing.T) {
+	var buf bytes.Buffer
+	out := lgr.Out
+	lgr.Out = &buf
+	defer func() { lgr.Out = out }()
+
+	msg, err := workers.NewMsg(`{"jid":"abc123","queue":"analyze","class":"Jobs::Analyze","args":["uuid-1"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobLogger(msg).WithField("uuid", "uuid-1").Info("hi")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON - %s: %s", err, buf.String())
+	}
+	for k, v := range map[string]string{"jid": "abc123", "queue": "analyze", "uuid": "uuid-1", "msg": "hi"} {
+		if line[k] != v {
+			t.Errorf("%s - got: %v, want: %s", k, line[k], v)
+		}
+	}
+}
+
+func TestConfigureLogger(t *testing.T) {
+	defer configureLogger(LogConfig{Level: "info", Format: "json"})
+
+	if err := configureLogger(LogConfig{Level: "loud", Format: "json"}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if err := configureLogger(LogConfig{Level: "debug", Format: "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
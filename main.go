@@ -4,67 +4,148 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/exercism/rikki/analysis/crystal"
-	"github.com/exercism/rikki/analysis/ruby"
 	"github.com/jrallison/go-workers"
+	"github.com/sirupsen/logrus"
 )
 
-var redisFlag = flag.String("redis", "redis://localhost:6379/0/", "Redis database to read queue from")
-var exercismFlag = flag.String("exercism", "http://localhost:4567", "Url of exercism api, e.g. http://exercism.io")
-var rubyAnalyzerFlag = flag.String("ruby-analyzer", "http://localhost:8989", "Url of ruby-analizer api, e.g. http://ruby-analyzer.exercism.io")
-var crystalAnalyzerFlag = flag.String("crystal-analyzer", "http://localhost:3000", "Url of crystal-analyzer api, e.g. http://crystal-analyzer.exercism.io")
+var configFlag = flag.String("config", os.Getenv("RIKKI_CONFIG"), "Path to a TOML config file")
 
-var lgr = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+// Flags override the config file and the environment. Their values are
+// read in applyFlags, and only when they're explicitly set.
+func init() {
+	flag.String("redis", "redis://localhost:6379/0/", "Redis database to read queue from")
+	flag.String("exercism", "http://localhost:4567", "Url of exercism api, e.g. http://exercism.io")
+	flag.String("env", "development", "Environment: development or production")
+	flag.String("exercism-auth", "hmac", "How to authenticate to the exercism api: hmac or shared-key")
+	flag.String("ruby-analyzer", "http://localhost:8989", "Url of ruby-analizer api, e.g. http://ruby-analyzer.exercism.io")
+	flag.String("crystal-analyzer", "http://localhost:3000", "Url of crystal-analyzer api, e.g. http://crystal-analyzer.exercism.io")
+	flag.String("http", ":9292", "Address for the health and metrics HTTP server; empty to disable")
+	flag.String("log-level", "info", "Log level: debug, info, warning or error")
+	flag.Int("pool", 30, "Size of the redis connection pool")
+	flag.Var(&queueFlag{}, "queue", "Queue to listen to, as name=job:concurrency; may be repeated")
+	flag.Var(trackFlag{}, "track-limit", "Maximum concurrent analyses for a track, as track=n; may be repeated")
+}
+
+// jobs are the kinds of work a queue can be bound to.
+var jobs = map[string]bool{
+	"analyze": true,
+	"hello":   true,
+	"welcome": true,
+}
 
 func main() {
 	rand.Seed(time.Now().UTC().UnixNano())
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s \n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [command]\n\nCommands:\n", os.Args[0])
+		for _, cmd := range commands {
+			fmt.Fprintf(os.Stderr, "  %-20s %s\n", cmd.usage, cmd.description)
+		}
+		fmt.Fprintf(os.Stderr, "\nWithout a command, rikki- processes jobs from the queues.\n\nFlags:\n")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
 
-	workers.Configure(redisConfig())
+	config, err := loadConfig(*configFlag, flag.CommandLine)
+	if err != nil {
+		lgr.Fatal(err)
+	}
+
+	if flag.NArg() > 0 {
+		cmd, ok := findCommand(flag.Arg(0))
+		if !ok {
+			flag.Usage()
+			os.Exit(2)
+		}
+		if err := cmd.fn(config, flag.Args()[1:]); err != nil {
+			lgr.Fatal(err)
+		}
+		return
+	}
+
+	if err := configureLogger(config.Log); err != nil {
+		lgr.Fatal(err)
+	}
+	if err := config.validate(jobs); err != nil {
+		lgr.Fatal(err)
+	}
+	work(config)
+}
+
+// work processes jobs from the configured queues until rikki- is stopped.
+func work(config *Config) {
+	lgr.WithFields(logrus.Fields{"env": config.Env, "source": config.secretSource()}).Info("using shared secret")
 
-	exercism := NewExercism(*exercismFlag, NewAuth().Key())
+	workers.Configure(redisConfig(config.Redis))
 
-	ruby.Host = *rubyAnalyzerFlag
-	crystal.Host = *crystalAnalyzerFlag
+	exercism := config.exercism()
+	config.configureAnalyzers()
 
-	analyzer, err := NewAnalyzer(exercism, commentDir())
+	analyzer, err := NewAnalyzer(exercism, config.Comments)
+	if err != nil {
+		lgr.Fatal(err)
+	}
+	analyzer.limiter = newTrackLimiter(config.Tracks)
+	store, err := openStore(config.DB)
 	if err != nil {
-		lgr.Print(err)
-		os.Exit(1)
+		lgr.Fatal(err)
 	}
-	workers.Process("analyze", analyzer.process, 4)
+	analyzer.audit = &auditLog{store: store}
+	analyzer.cache = newAnalysisCache(config.Cache.Size, config.Cache.TTL.Duration)
+	limits := newCommentLimiter(config.Limits, workers.Config.Pool)
+	analyzer.limits = limits
+	analyzer.mentor = newMentorOnly(config.Mentor)
+	votes := config.votes(store)
+	analyzer.votes = votes
 
-	hello, err := NewHello(exercism, commentDir())
+	hello, err := NewHello(exercism, config.Comments)
 	if err != nil {
-		lgr.Print(err)
-		os.Exit(1)
+		lgr.Fatal(err)
+	}
+	hello.limits = limits
+	hello.cache = analyzer.cache
+	hello.limiter = analyzer.limiter
+
+	processors := map[string]func(*workers.Msg){
+		"analyze": analyzer.process,
+		"hello":   hello.process,
+		"welcome": hello.welcome,
+	}
+	for _, q := range config.Queues {
+		workers.Process(q.Name, processors[q.Job], q.Concurrency)
+	}
+
+	checks := []check{
+		{"redis", redisCheck(workers.Config.Pool)},
+		{"comments", commentsCheck(analyzer)},
+		{"exercism", reachableCheck(config.Exercism.URL)},
+		{"ruby-analyzer", reachableCheck(config.Analyzers.Ruby)},
+		{"crystal-analyzer", reachableCheck(config.Analyzers.Crystal)},
+	}
+	serve(config.HTTP.Addr, newServeMux(checks, store))
+	if votes != nil {
+		serve(config.Votes.Addr, newVotesMux(votes))
 	}
-	workers.Process("hello", hello.process, 4)
 
 	workers.Run()
 }
 
-func redisConfig() map[string]string {
-	url, err := url.Parse(*redisFlag)
+func redisConfig(rc RedisConfig) map[string]string {
+	url, err := url.Parse(rc.URL)
 	if err != nil {
 		panic(err)
 	}
 	config := map[string]string{
 		"server":   url.Host,
 		"database": strings.Trim(url.Path, "/"),
-		"pool":     "30",
+		"pool":     strconv.Itoa(rc.Pool),
 		"process":  "1",
 	}
 	if url.User != nil {
@@ -76,14 +157,6 @@ func redisConfig() map[string]string {
 	return config
 }
 
-func commentDir() string {
-	dir := os.Getenv("RIKKI_FEEDBACK_DIR")
-	if dir == "" {
-		dir = "comments"
-	}
-	return dir
-}
-
 func read(path string) ([]byte, error) {
 	if _, err := os.Stat(path); err != nil {
 		return nil, err
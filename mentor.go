@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/exercism/rikki/analysis"
+)
+
+// mentorOnly decides which findings are left as private notes for mentors,
+// rather than posted as comments for the student: everything on some
+// tracks, and smells of some severities on every track.
+// A nil mentorOnly leaves everything to the student.
+type mentorOnly struct {
+	tracks     map[string]bool
+	severities map[analysis.Severity]bool
+}
+
+func newMentorOnly(config MentorConfig) *mentorOnly {
+	if len(config.Tracks) == 0 && len(config.Severities) == 0 {
+		return nil
+	}
+	m := &mentorOnly{
+		tracks:     map[string]bool{},
+		severities: map[analysis.Severity]bool{},
+	}
+	for _, track := range config.Tracks {
+		m.tracks[track] = true
+	}
+	for _, severity := range config.Severities {
+		m.severities[analysis.Severity(severity)] = true
+	}
+	return m
+}
+
+// private reports whether a finding is for mentors only.
+func (m *mentorOnly) private(track, key string) bool {
+	if m == nil {
+		return false
+	}
+	if m.tracks[track] {
+		return true
+	}
+	smell, ok := catalogs[track].Lookup(key)
+	return ok && m.severities[smell.Severity]
+}
+
+// mentorNote lists the findings that are for mentors only, with what the
+// analyzer's catalog says about each of them.
+func mentorNote(track string, smells []string) []byte {
+	var b bytes.Buffer
+	b.WriteString("rikki- found these, and left them for mentors:\n\n")
+	for _, key := range smells {
+		fmt.Fprintf(&b, "* `%s`", key)
+		if smell, ok := catalogs[track].Lookup(key); ok {
+			fmt.Fprintf(&b, " (%s): %s", smell.Severity, smell.Description)
+		}
+		b.WriteString("\n")
+	}
+	return b.Bytes()
+}
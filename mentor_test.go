@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/exercism/rikki/exercismtest"
+)
+
+func TestMentorOnlyPrivate(t *testing.T) {
+	tests := []struct {
+		desc    string
+		config  MentorConfig
+		track   string
+		smell   string
+		private bool
+	}{
+		{"not configured", MentorConfig{}, "go", "stub", false},
+		{"mentor-only track", MentorConfig{Tracks: []string{"ruby"}}, "ruby", "loops/nesting", true},
+		{"another track", MentorConfig{Tracks: []string{"ruby"}}, "go", "stub", false},
+		{"mentor-only severity", MentorConfig{Severities: []string{"blocking"}}, "go", "go-vet", true},
+		{"another severity", MentorConfig{Severities: []string{"blocking"}}, "go", "gofmt", false},
+		{"unknown smell", MentorConfig{Severities: []string{"info"}}, "go", "nonsense", false},
+	}
+
+	for _, test := range tests {
+		if private := newMentorOnly(test.config).private(test.track, test.smell); private != test.private {
+			t.Errorf("%s - got: %t, want: %t", test.desc, private, test.private)
+		}
+	}
+}
+
+// unformattedCode is a go/leap solution with two smells: stub, then gofmt.
+const unformattedCode = "// Package leap is a stub.\npackage leap\n\n// Leap is a stub.\nfunc Leap() {\n  return\n}\n"
+
+func TestAnalyzerLeavesNotesForMentors(t *testing.T) {
+	stub, err := ioutil.ReadFile("comments/analyzer/go/stub.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gofmt, err := ioutil.ReadFile("comments/analyzer/go/gofmt.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "rikki-mentor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		desc    string
+		config  MentorConfig
+		uuid    string
+		comment []byte
+		notes   []string
+		result  string
+	}{
+		{"public comment", MentorConfig{Severities: []string{"blocking"}}, "stubbed", stub, nil, "commented"},
+		{"note by track", MentorConfig{Tracks: []string{"go"}}, "stubbed", nil, []string{"stub"}, "noted"},
+		{"note by severity", MentorConfig{Severities: []string{"info"}}, "stubbed", nil, []string{"stub"}, "noted"},
+		{"comment on the next public smell", MentorConfig{Severities: []string{"info"}}, "unformatted", gofmt, []string{"stub"}, "commented"},
+		{"every finding noted", MentorConfig{Tracks: []string{"go"}}, "unformatted", nil, []string{"stub", "gofmt"}, "noted"},
+	}
+
+	for i, test := range tests {
+		analyzer, api := newTestAnalyzer(t)
+		api.AddSubmission("unformatted", exercismtest.Submission{
+			TrackID: "go",
+			Slug:    "leap",
+			Files:   map[string]string{"leap.go": unformattedCode},
+		})
+		analyzer.mentor = newMentorOnly(test.config)
+		store := newTestStore(t, filepath.Join(dir, fmt.Sprintf("%d.db", i)))
+		analyzer.audit = &auditLog{store: store}
+		analyzer.process(newTestMsg(t, "analyze", test.uuid))
+		comments, notes := api.Comments(), api.Notes()
+		api.Close()
+
+		if test.comment == nil && len(comments) != 0 || test.comment != nil && (len(comments) != 1 || !bytes.HasPrefix([]byte(comments[0].Body), test.comment)) {
+			t.Errorf("%s - got comments %q, want %q", test.desc, comments, test.comment)
+		}
+		if test.notes == nil && len(notes) != 0 || test.notes != nil && (len(notes) != 1 || notes[0].Body != string(mentorNote("go", test.notes))) {
+			t.Errorf("%s - got notes %q, want one listing %q", test.desc, notes, test.notes)
+		}
+
+		entries, err := analyzer.audit.entries(auditFilter{})
+		store.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := entries[0]; got.Result != test.result || !reflect.DeepEqual(got.Notes, test.notes) {
+			t.Errorf("%s - got: %s %q, want: %s %q", test.desc, got.Result, got.Notes, test.result, test.notes)
+		}
+	}
+}
+
+func TestNotesDontCountTowardsLimits(t *testing.T) {
+	analyzer, api := newTestAnalyzer(t)
+	defer api.Close()
+	api.AddSubmission("abc", exercismtest.Submission{
+		TrackID:  "go",
+		Slug:     "leap",
+		Username: "alice",
+		Files:    map[string]string{"leap.go": unformattedCode},
+	})
+	analyzer.mentor = newMentorOnly(MentorConfig{Severities: []string{"info"}})
+	counts := map[string]int{}
+	analyzer.limits = &commentLimiter{
+		limits: CommentLimitsConfig{Student: 1, Over: "drop"},
+		take:   memoryTake(counts),
+		count:  memoryCount(counts),
+		now:    time.Now,
+	}
+
+	// The first time, the comment is posted, and counted; the second time,
+	// it's dropped. The note goes to mentors both times.
+	for i := 0; i < 2; i++ {
+		analyzer.process(newTestMsg(t, "analyze", "abc"))
+	}
+	if n := len(api.Comments()); n != 1 {
+		t.Errorf("got %d comments, want 1", n)
+	}
+	if n := len(api.Notes()); n != 2 {
+		t.Errorf("got %d notes, want 2", n)
+	}
+	for key, n := range counts {
+		if n != 1 {
+			t.Errorf("%s - got: %d, want: 1", key, n)
+		}
+	}
+}
+
+func TestNotesWhenGlobalLimitIsFull(t *testing.T) {
+	analyzer, api := newTestAnalyzer(t)
+	defer api.Close()
+	api.AddSubmission("abc", exercismtest.Submission{TrackID: "go", Slug: "leap", Files: map[string]string{"leap.go": unformattedCode}})
+	analyzer.mentor = newMentorOnly(MentorConfig{Severities: []string{"info"}})
+	limits := &commentLimiter{
+		limits: CommentLimitsConfig{Global: 1, Over: "drop"},
+		now:    time.Now,
+	}
+	counts := map[string]int{limits.key("global", ""): 1}
+	limits.take, limits.count = memoryTake(counts), memoryCount(counts)
+	analyzer.limits = limits
+
+	analyzer.process(newTestMsg(t, "analyze", "abc"))
+	if n := len(api.Comments()); n != 0 {
+		t.Errorf("got %d comments, want the comment dropped", n)
+	}
+	if n := len(api.Notes()); n != 1 {
+		t.Errorf("got %d notes, want 1", n)
+	}
+}
+
+func TestNotesNotLeftTwice(t *testing.T) {
+	analyzer, api := newTestAnalyzer(t)
+	defer api.Close()
+	api.AddSubmission("abc", exercismtest.Submission{TrackID: "go", Slug: "leap", Username: "alice", Files: map[string]string{"leap.go": unformattedCode}})
+	analyzer.mentor = newMentorOnly(MentorConfig{Severities: []string{"info"}})
+	limits := &commentLimiter{
+		limits: CommentLimitsConfig{Student: 1, Over: "defer"},
+		now:    time.Now,
+	}
+	counts := map[string]int{limits.key("student", "alice"): 1}
+	limits.take, limits.count = memoryTake(counts), memoryCount(counts)
+	analyzer.limits = limits
+
+	// The comment is deferred, but the note isn't held back with it.
+	msg := newTestMsg(t, "analyze", "abc")
+	analyzer.process(msg)
+	if n, m := len(api.Comments()), len(api.Notes()); n != 0 || m != 1 {
+		t.Fatalf("got %d comments and %d notes, want 0 and 1", n, m)
+	}
+
+	// When the job comes back, only the comment is posted.
+	delete(counts, limits.key("student", "alice"))
+	analyzer.process(msg)
+	if n, m := len(api.Comments()), len(api.Notes()); n != 1 || m != 1 {
+		t.Errorf("got %d comments and %d notes, want 1 and 1", n, m)
+	}
+}
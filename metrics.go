@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are exposed for scraping on the /metrics endpoint.
+var (
+	jobsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikki",
+		Name:      "jobs_processed_total",
+		Help:      "Jobs processed, by queue, track and outcome.",
+	}, []string{"queue", "track", "result"})
+
+	smellsDetected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikki",
+		Name:      "smells_detected_total",
+		Help:      "Smells detected in submissions, by track and smell key.",
+	}, []string{"track", "smell"})
+
+	commentsPosted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikki",
+		Name:      "comments_posted_total",
+		Help:      "Comments posted to exercism, by track, smell key and variant.",
+	}, []string{"track", "smell", "variant"})
+
+	notesPosted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikki",
+		Name:      "notes_posted_total",
+		Help:      "Findings left for mentors in private notes, by track and smell.",
+	}, []string{"track", "smell"})
+
+	commentsLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikki",
+		Name:      "comments_limited_total",
+		Help:      "Comments held back by a rate limit, by limit (student, track or global) and action (deferred or dropped).",
+	}, []string{"limit", "action"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikki",
+		Name:      "analysis_cache_total",
+		Help:      "Lookups in the analysis cache, by track and result (hit or miss).",
+	}, []string{"track", "result"})
+
+	apiErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rikki",
+		Name:      "api_errors_total",
+		Help:      "Failed requests to the exercism API, by endpoint and response status.",
+	}, []string{"endpoint", "status"})
+
+	analysisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "rikki",
+		Name:      "analysis_duration_seconds",
+		Help:      "Time spent analyzing a submission that wasn't cached, by track.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"track"})
+
+	apiDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "rikki",
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of requests to the exercism API, by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		jobsProcessed,
+		smellsDetected,
+		commentsPosted,
+		notesPosted,
+		commentsLimited,
+		cacheLookups,
+		apiErrors,
+		analysisDuration,
+		apiDuration,
+	)
+}
+
+// observeAPI records the latency of a call to the exercism API, and counts
+// it as an error if it didn't get the expected response.
+// A status of zero means the request never got a response.
+func observeAPI(endpoint string, start time.Time, status int, err error) {
+	apiDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return
+	}
+	label := "none"
+	if status > 0 {
+		label = strconv.Itoa(status)
+	}
+	apiErrors.WithLabelValues(endpoint, label).Inc()
+}
+
+// outcome is how a job ended, recorded once the job returns.
+type outcome struct {
+	queue, track, result string
+}
+
+func (o *outcome) record() {
+	jobsProcessed.WithLabelValues(o.queue, o.track, o.result).Inc()
+}
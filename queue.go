@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jrallison/go-workers"
+)
+
+// requeueDelay is how long a deferred job waits before it is retried.
+const requeueDelay = 10 * time.Second
+
+// errTrackBusy means a track is at its concurrency limit.
+var errTrackBusy = errors.New("track is at its concurrency limit")
+
+// queueOf names the queue a job was taken from.
+func queueOf(msg *workers.Msg) string {
+	return msg.Get("queue").MustString()
+}
+
+// requeue puts a job back on the queue it came from, to be picked up again
+// after a delay. The queue and class are taken from the sidekiq message itself.
+func requeue(msg *workers.Msg, delay time.Duration) error {
+	queue, err := msg.Get("queue").String()
+	if err != nil {
+		return fmt.Errorf("unable to determine queue of job %s - %s", msg.Jid(), err)
+	}
+	class, err := msg.Get("class").String()
+	if err != nil {
+		return fmt.Errorf("unable to determine class of job %s - %s", msg.Jid(), err)
+	}
+	_, err = workers.EnqueueIn(queue, class, delay.Seconds(), msg.Args().Interface())
+	return err
+}
+
+// retry arranges for a job to run again if err may clear up on its own.
+// When the API rate limits us, the job goes back on the queue for as long as
+// it asked us to wait. Other temporary failures panic, which the go-workers
+// retry middleware turns into a retry with backoff.
+// Anything else is left as a failed job.
+func (o *outcome) retry(msg *workers.Msg, err error) {
+	if e, ok := err.(*RateLimitedError); ok {
+		delay := e.RetryAfter
+		if delay == 0 {
+			delay = requeueDelay
+		}
+		if requeue(msg, delay) == nil {
+			o.result = "deferred"
+		}
+		return
+	}
+	if retryable(err) {
+		o.result = "retry"
+		panic(err)
+	}
+}
+
+// trackLimiter caps the number of analyses running concurrently per track.
+// Tracks without a configured limit are never held back.
+// A nil trackLimiter does not limit anything.
+type trackLimiter struct {
+	mu    sync.Mutex
+	limit map[string]int
+	busy  map[string]int
+}
+
+func newTrackLimiter(tracks map[string]TrackConfig) *trackLimiter {
+	limit := map[string]int{}
+	for track, t := range tracks {
+		if t.Concurrency > 0 {
+			limit[track] = t.Concurrency
+		}
+	}
+	return &trackLimiter{
+		limit: limit,
+		busy:  map[string]int{},
+	}
+}
+
+// acquire reserves a slot for the track, and reports whether one was available.
+// Callers that get a slot must release it when they are done.
+func (l *trackLimiter) acquire(track string) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if n, ok := l.limit[track]; ok && l.busy[track] >= n {
+		return false
+	}
+	l.busy[track]++
+	return true
+}
+
+func (l *trackLimiter) release(track string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.busy[track] > 0 {
+		l.busy[track]--
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/jrallison/go-workers"
+	"github.com/sirupsen/logrus"
+)
+
+// rateWindow is how long the comment limits count over.
+const rateWindow = time.Minute
+
+// takeScript counts a comment against every limit at once, but only if none
+// of them has been reached. It returns the (1-based) index of the first full
+// limit, or 0 when the comment may be posted.
+// KEYS are the counters; ARGV the limits, followed by the window in seconds.
+var takeScript = redis.NewScript(-1, `
+for i, key in ipairs(KEYS) do
+	if tonumber(redis.call("GET", key) or "0") >= tonumber(ARGV[i]) then
+		return i
+	end
+end
+for i, key in ipairs(KEYS) do
+	redis.call("INCR", key)
+	redis.call("EXPIRE", key, ARGV[#KEYS + 1])
+end
+return 0
+`)
+
+// commentLimiter caps how many comments rikki- posts a minute, per student,
+// per track and overall, so that a backfill doesn't flood the site.
+// The counts are kept in redis, and shared by every rikki- process.
+// A nil commentLimiter doesn't limit anything.
+type commentLimiter struct {
+	limits CommentLimitsConfig
+	// take counts a comment against the limits and reports the index of
+	// the first full one, or -1 if there was room under all of them.
+	take func(keys []string, limits []int) (int, error)
+	// count reads a counter without adding to it.
+	count func(key string) (int, error)
+	now   func() time.Time
+}
+
+func newCommentLimiter(config CommentLimitsConfig, pool *redis.Pool) *commentLimiter {
+	if config.Student == 0 && config.Track == 0 && config.Global == 0 {
+		return nil
+	}
+	return &commentLimiter{
+		limits: config,
+		take:   redisTake(pool),
+		count:  redisCount(pool),
+		now:    time.Now,
+	}
+}
+
+func redisTake(pool *redis.Pool) func([]string, []int) (int, error) {
+	return func(keys []string, limits []int) (int, error) {
+		conn := pool.Get()
+		defer conn.Close()
+
+		args := []interface{}{len(keys)}
+		for _, key := range keys {
+			args = append(args, key)
+		}
+		for _, n := range limits {
+			args = append(args, n)
+		}
+		args = append(args, int(rateWindow.Seconds()))
+		i, err := redis.Int(takeScript.Do(conn, args...))
+		return i - 1, err
+	}
+}
+
+func redisCount(pool *redis.Pool) func(string) (int, error) {
+	return func(key string) (int, error) {
+		conn := pool.Get()
+		defer conn.Close()
+
+		n, err := redis.Int(conn.Do("GET", key))
+		if err == redis.ErrNil {
+			return 0, nil
+		}
+		return n, err
+	}
+}
+
+// key names the counter for a scope in the current window.
+func (l *commentLimiter) key(scope, id string) string {
+	window := l.now().Unix() / int64(rateWindow.Seconds())
+	return fmt.Sprintf("rikki:comments:%s:%s:%d", scope, id, window)
+}
+
+// allow counts a comment to the student on the track, and reports which
+// limit stops it from being posted, if any: "student", "track" or "global".
+// Limits that don't apply, such as the student limit for a comment we don't
+// know the student of, are skipped.
+func (l *commentLimiter) allow(username, track string) (string, error) {
+	if l == nil {
+		return "", nil
+	}
+	var scopes, keys []string
+	var limits []int
+	add := func(scope, id string, n int) {
+		if n > 0 && (scope == "global" || id != "") {
+			scopes = append(scopes, scope)
+			keys = append(keys, l.key(scope, id))
+			limits = append(limits, n)
+		}
+	}
+	add("student", username, l.limits.Student)
+	add("track", track, l.limits.Track)
+	add("global", "", l.limits.Global)
+	if len(keys) == 0 {
+		return "", nil
+	}
+
+	i, err := l.take(keys, limits)
+	if err != nil || i < 0 {
+		return "", err
+	}
+	return scopes[i], nil
+}
+
+// untilNextWindow is how long until the limits start counting afresh.
+func (l *commentLimiter) untilNextWindow() time.Duration {
+	now := l.now()
+	return now.Truncate(rateWindow).Add(rateWindow).Sub(now)
+}
+
+// deferral is how long to put a job over the limit back on the queue for:
+// until the next window, and then some way into it, so that the jobs held
+// back don't all come back at once.
+func (l *commentLimiter) deferral() time.Duration {
+	return l.untilNextWindow() + time.Duration(rand.Int63n(int64(rateWindow)))
+}
+
+// wait holds a job back before it does any work if the global limit has
+// already been reached this window, since it couldn't post anything anyway.
+// It spares the exercism API, and the analyzers, from fetching and
+// analyzing solutions only for their comments to be held back.
+// It reports whether the job may go ahead.
+func (l *commentLimiter) wait(msg *workers.Msg, job *outcome, log *logrus.Entry) bool {
+	if l == nil || l.limits.Global == 0 {
+		return true
+	}
+	n, err := l.count(l.key("global", ""))
+	if err != nil {
+		log.WithError(err).Warn("unable to check comment limits")
+		return true
+	}
+	if n < l.limits.Global {
+		return true
+	}
+	return l.over(msg, job, log, "global")
+}
+
+// admit decides whether a job may post its comment now. Over the limit,
+// the job is dropped or put back on the queue until the next window,
+// depending on the configuration. If redis can't tell us, the comment
+// is posted anyway: the limits protect the site, they aren't worth
+// losing comments over.
+func (l *commentLimiter) admit(msg *workers.Msg, job *outcome, log *logrus.Entry, username, track string) bool {
+	scope, err := l.allow(username, track)
+	if err != nil {
+		log.WithError(err).Warn("unable to check comment limits")
+		return true
+	}
+	if scope == "" {
+		return true
+	}
+	return l.over(msg, job, log, scope)
+}
+
+// over drops the job, or puts it back on the queue, for being over the limit.
+// It always reports false, for callers to return.
+func (l *commentLimiter) over(msg *workers.Msg, job *outcome, log *logrus.Entry, scope string) bool {
+	log = log.WithField("limit", scope)
+	if l.limits.Over == "drop" {
+		log.Info("dropped - over the comment limit")
+		commentsLimited.WithLabelValues(scope, "dropped").Inc()
+		job.result = "dropped"
+		return false
+	}
+	if err := requeue(msg, l.deferral()); err != nil {
+		log.WithError(err).Error("unable to defer job")
+		return false
+	}
+	log.Info("deferred - over the comment limit")
+	commentsLimited.WithLabelValues(scope, "deferred").Inc()
+	job.result = "deferred"
+	return false
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/sirupsen/logrus"
+)
+
+// memoryTake counts comments the way takeScript does, without redis.
+func memoryTake(counts map[string]int) func([]string, []int) (int, error) {
+	return func(keys []string, limits []int) (int, error) {
+		for i, key := range keys {
+			if counts[key] >= limits[i] {
+				return i, nil
+			}
+		}
+		for _, key := range keys {
+			counts[key]++
+		}
+		return -1, nil
+	}
+}
+
+// memoryCount reads the counts kept by memoryTake.
+func memoryCount(counts map[string]int) func(string) (int, error) {
+	return func(key string) (int, error) {
+		return counts[key], nil
+	}
+}
+
+// testPool connects to the redis at REDIS_URL, or on localhost, and skips
+// the test if there isn't one.
+func testPool(t *testing.T) *redis.Pool {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		url = "redis://localhost:6379/15"
+	}
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.DialURL(url) }}
+	conn := pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		t.Skipf("redis isn't available at %s - %s", url, err)
+	}
+	return pool
+}
+
+func TestCommentLimiterAllow(t *testing.T) {
+	now := time.Date(2016, 3, 1, 12, 0, 30, 0, time.UTC)
+	l := &commentLimiter{
+		limits: CommentLimitsConfig{Student: 1, Track: 2, Global: 3},
+		take:   memoryTake(map[string]int{}),
+		now:    func() time.Time { return now },
+	}
+
+	tests := []struct {
+		desc     string
+		username string
+		track    string
+		limit    string
+	}{
+		{"first comment", "alice", "go", ""},
+		{"same student", "alice", "go", "student"},
+		{"another student", "bob", "go", ""},
+		{"track is full", "carol", "go", "track"},
+		{"another track", "carol", "ruby", ""},
+		{"everything is full", "dave", "crystal", "global"},
+	}
+	for _, test := range tests {
+		limit, err := l.allow(test.username, test.track)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if limit != test.limit {
+			t.Errorf("%s - got: %q, want: %q", test.desc, limit, test.limit)
+		}
+	}
+
+	now = now.Add(30 * time.Second)
+	if limit, _ := l.allow("alice", "go"); limit != "" {
+		t.Errorf("the limits should start afresh each minute, got: %q", limit)
+	}
+}
+
+func TestCommentLimiterRedis(t *testing.T) {
+	pool := testPool(t)
+	defer pool.Close()
+
+	// A window of our own, so that runs don't count against each other.
+	now := time.Unix(0, 0).Add(time.Duration(rand.Int63n(1e6)) * rateWindow)
+	l := newCommentLimiter(CommentLimitsConfig{Student: 1, Track: 2, Global: 3}, pool)
+	l.now = func() time.Time { return now }
+	defer func() {
+		conn := pool.Get()
+		defer conn.Close()
+		for _, key := range []string{l.key("student", "alice"), l.key("student", "bob"), l.key("student", "carol"), l.key("track", "go"), l.key("track", "ruby"), l.key("global", "")} {
+			conn.Do("DEL", key)
+		}
+	}()
+
+	tests := []struct {
+		username string
+		track    string
+		limit    string
+	}{
+		{"alice", "go", ""},
+		{"alice", "go", "student"},
+		{"bob", "go", ""},
+		{"carol", "go", "track"},
+		{"carol", "ruby", ""},
+		{"dave", "ruby", "global"},
+	}
+	for i, test := range tests {
+		limit, err := l.allow(test.username, test.track)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if limit != test.limit {
+			t.Errorf("%d %s/%s - got: %q, want: %q", i, test.username, test.track, limit, test.limit)
+		}
+	}
+
+	// Comments that were held back aren't counted.
+	counts := map[string]int{l.key("student", "alice"): 1, l.key("track", "go"): 2, l.key("global", ""): 3}
+	for key, want := range counts {
+		got, err := l.count(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s - got: %d, want: %d", key, got, want)
+		}
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+	ttl, err := redis.Int(conn.Do("TTL", l.key("global", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl <= 0 || ttl > int(rateWindow.Seconds()) {
+		t.Errorf("got ttl: %d, want it to expire within the window", ttl)
+	}
+}
+
+func TestCommentLimiterWait(t *testing.T) {
+	log := logrus.NewEntry(logrus.New())
+	now := time.Date(2016, 3, 1, 12, 0, 30, 0, time.UTC)
+	counts := map[string]int{}
+	l := &commentLimiter{
+		limits: CommentLimitsConfig{Student: 1, Global: 2, Over: "drop"},
+		take:   memoryTake(counts),
+		count:  memoryCount(counts),
+		now:    func() time.Time { return now },
+	}
+
+	for i, username := range []string{"alice", "bob", "carol"} {
+		job := &outcome{result: "error"}
+		wait := l.wait(newTestMsg(t, "analyze", "abc"), job, log)
+		if want := i < 2; wait != want {
+			t.Errorf("%s - got: %t, want: %t", username, wait, want)
+		}
+		if wait {
+			l.allow(username, "go")
+		} else if job.result != "dropped" {
+			t.Errorf("%s - got: %s, want: dropped", username, job.result)
+		}
+	}
+
+	// The student limit is only known once the solution is fetched.
+	counts = map[string]int{l.key("student", "alice"): 1}
+	l.take, l.count = memoryTake(counts), memoryCount(counts)
+	if !l.wait(newTestMsg(t, "analyze", "abc"), &outcome{}, log) {
+		t.Error("only the global limit should hold a job back before it's fetched")
+	}
+}
+
+func TestCommentLimiterSkipsUnknown(t *testing.T) {
+	counts := map[string]int{}
+	l := &commentLimiter{
+		limits: CommentLimitsConfig{Student: 1, Track: 1},
+		take:   memoryTake(counts),
+		now:    time.Now,
+	}
+	for i := 0; i < 3; i++ {
+		if limit, _ := l.allow("", ""); limit != "" {
+			t.Errorf("got: %q, want no limit for a comment we know nothing about", limit)
+		}
+	}
+	if len(counts) != 0 {
+		t.Errorf("nothing should be counted, got: %v", counts)
+	}
+}
+
+func TestCommentLimiterAdmit(t *testing.T) {
+	log := logrus.NewEntry(logrus.New())
+
+	tests := []struct {
+		desc   string
+		over   string
+		take   func([]string, []int) (int, error)
+		admit  bool
+		result string
+	}{
+		{"under the limit", "drop", func([]string, []int) (int, error) { return -1, nil }, true, "error"},
+		{"dropped", "drop", func([]string, []int) (int, error) { return 0, nil }, false, "dropped"},
+		{"deferred", "defer", func([]string, []int) (int, error) { return 0, nil }, false, "deferred"},
+		{"redis is down", "drop", func([]string, []int) (int, error) { return 0, errors.New("connection refused") }, true, "error"},
+	}
+	for _, test := range tests {
+		l := &commentLimiter{
+			limits: CommentLimitsConfig{Global: 1, Over: test.over},
+			take:   test.take,
+			now:    time.Now,
+		}
+		job := &outcome{result: "error"}
+		admit := l.admit(newTestMsg(t, "analyze", "abc"), job, log, "alice", "go")
+		if admit != test.admit || job.result != test.result {
+			t.Errorf("%s - got: %t %s, want: %t %s", test.desc, admit, job.result, test.admit, test.result)
+		}
+	}
+}
+
+func TestUntilNextWindow(t *testing.T) {
+	now := time.Date(2016, 3, 1, 12, 0, 45, 0, time.UTC)
+	l := &commentLimiter{now: func() time.Time { return now }}
+	if got := l.untilNextWindow(); got != 15*time.Second {
+		t.Errorf("got: %s, want: 15s", got)
+	}
+
+	delays := map[time.Duration]bool{}
+	for i := 0; i < 20; i++ {
+		got := l.deferral()
+		if got < 15*time.Second || got >= 75*time.Second {
+			t.Errorf("got: %s, want somewhere in the next window", got)
+		}
+		delays[got] = true
+	}
+	if len(delays) < 2 {
+		t.Error("deferred jobs should be spread over the next window")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// replayed is what the current analyzers make of a past submission,
+// next to what was originally recorded.
+type replayed struct {
+	uuid     string
+	track    string
+	slug     string
+	original *auditEntry
+	smells   []string
+	comment  string
+	err      error
+}
+
+// changed reports whether the analysis came out differently this time.
+func (r *replayed) changed() bool {
+	if r.err != nil || r.original == nil {
+		return false
+	}
+	added, removed := diffSmells(r.original.Smells, r.smells)
+	return len(added) > 0 || len(removed) > 0 || r.original.posted() != r.comment
+}
+
+// replay runs the current analyzers over past submissions, without posting
+// anything. The originals are the latest completed analyses, by uuid.
+func replay(ctx context.Context, analyzer *Analyzer, uuids []string, originals map[string]*auditEntry) []*replayed {
+	var results []*replayed
+	for _, uuid := range uuids {
+		r := &replayed{uuid: uuid, original: originals[uuid]}
+		results = append(results, r)
+
+		solution, err := analyzer.exercism.FetchSolution(ctx, uuid)
+		if err != nil {
+			r.err = err
+			continue
+		}
+		r.track, r.slug = solution.TrackID, solution.Slug
+
+		fn, ok := analyzers[solution.TrackID]
+		if !ok {
+			r.err = fmt.Errorf("rikki- doesn't support the %s track", solution.TrackID)
+			continue
+		}
+		rev, err := analyzer.analyze(fn, solution)
+		if err != nil {
+			r.err = err
+			continue
+		}
+		r.smells, r.comment = rev.smells, rev.smell
+	}
+	return results
+}
+
+// latestEntries indexes the audit log by uuid, keeping the latest entry for
+// each whose analysis completed. Jobs that failed or were deferred before
+// they got that far say nothing about what the analyzers found.
+func latestEntries(entries []*auditEntry) map[string]*auditEntry {
+	latest := map[string]*auditEntry{}
+	for _, e := range entries {
+		if e.Analyzed {
+			latest[e.UUID] = e
+		}
+	}
+	return latest
+}
+
+// readUUIDs reads one uuid per line, skipping blank lines and # comments.
+func readUUIDs(r io.Reader) ([]string, error) {
+	var uuids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		uuids = append(uuids, line)
+	}
+	return uuids, scanner.Err()
+}
+
+// diffSmells lists the smells that were added and removed.
+func diffSmells(before, after []string) (added, removed []string) {
+	in := func(s string, list []string) bool {
+		for _, v := range list {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+	for _, s := range after {
+		if !in(s, before) {
+			added = append(added, s)
+		}
+	}
+	for _, s := range before {
+		if !in(s, after) {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// printReplay reports the submissions whose analysis changed, and the ones
+// that couldn't be replayed, followed by a summary.
+func printReplay(w io.Writer, results []*replayed) {
+	var changed, unchanged, unknown, failed int
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			failed++
+			fmt.Fprintf(w, "%s: error - %s\n", r.uuid, r.err)
+		case r.original == nil:
+			unknown++
+			fmt.Fprintf(w, "%s %s/%s: no record of the original analysis\n", r.uuid, r.track, r.slug)
+			fmt.Fprintf(w, "  smells: %s\n", orNone(strings.Join(r.smells, " ")))
+			fmt.Fprintf(w, "  comment: %s\n", orNone(r.comment))
+		case r.changed():
+			changed++
+			fmt.Fprintf(w, "%s %s/%s:\n", r.uuid, r.track, r.slug)
+			added, removed := diffSmells(r.original.Smells, r.smells)
+			if len(added) > 0 || len(removed) > 0 {
+				var diff []string
+				for _, s := range added {
+					diff = append(diff, "+"+s)
+				}
+				for _, s := range removed {
+					diff = append(diff, "-"+s)
+				}
+				fmt.Fprintf(w, "  smells: %s\n", strings.Join(diff, " "))
+			}
+			if r.original.posted() != r.comment {
+				fmt.Fprintf(w, "  comment: %s -> %s\n", orNone(r.original.posted()), orNone(r.comment))
+			}
+		default:
+			unchanged++
+		}
+	}
+	fmt.Fprintf(w, "\n%d replayed: %d changed, %d unchanged, %d without a record, %d failed\n",
+		len(results), changed, unchanged, unknown, failed)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/exercism/rikki/exercismtest"
+)
+
+func TestReadUUIDs(t *testing.T) {
+	uuids, err := readUUIDs(strings.NewReader("abc\n\n# skip me\n  def  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"abc", "def"}; !reflect.DeepEqual(uuids, want) {
+		t.Errorf("got: %s, want: %s", uuids, want)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	analyzer, api := newTestAnalyzer(t)
+	defer api.Close()
+	stub := map[string]string{"leap.go": stubbedCode}
+	api.AddSubmission("same", exercismtest.Submission{TrackID: "go", Slug: "leap", Files: stub})
+	api.AddSubmission("changed", exercismtest.Submission{TrackID: "go", Slug: "leap", Files: stub})
+	api.AddSubmission("new", exercismtest.Submission{TrackID: "go", Slug: "leap", Files: stub})
+	originals := map[string]*auditEntry{
+		"same":    {UUID: "same", Analyzed: true, Smells: []string{"stub"}, Comment: "stub", Result: "commented"},
+		"changed": {UUID: "changed", Analyzed: true, Smells: []string{"gofmt"}, Comment: "gofmt", Result: "commented"},
+	}
+
+	results := replay(context.Background(), analyzer, []string{"same", "changed", "new", "missing"}, originals)
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	if results[0].changed() || !results[1].changed() {
+		t.Errorf("changed - got: %t, %t, want: false, true", results[0].changed(), results[1].changed())
+	}
+	if results[3].err == nil {
+		t.Error("expected an error for a submission the API doesn't know")
+	}
+
+	// Nothing is posted in a replay.
+	if comments := api.Comments(); len(comments) > 0 {
+		t.Errorf("expected no comments, got %d", len(comments))
+	}
+
+	var buf bytes.Buffer
+	printReplay(&buf, results)
+	for _, want := range []string{
+		"changed go/leap:\n  smells: +stub -gofmt\n  comment: gofmt -> stub\n",
+		"new go/leap: no record of the original analysis\n",
+		"missing: error - ",
+		"4 replayed: 1 changed, 1 unchanged, 1 without a record, 1 failed\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected the report to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "same go/leap") {
+		t.Errorf("expected unchanged submissions to be left out, got:\n%s", buf.String())
+	}
+}
+
+func TestLatestEntries(t *testing.T) {
+	entries := []*auditEntry{
+		{UUID: "abc", Analyzed: true, Smells: []string{"stub"}, Comment: "stub", Result: "commented"},
+		{UUID: "abc", Result: "deferred"},
+		{UUID: "abc", Result: "error", Error: "analyzer is down"},
+		{UUID: "def", Analyzed: true, Smells: []string{"gofmt"}, Comment: "gofmt", Result: "dropped"},
+		{UUID: "ghi", Result: "skipped"},
+	}
+	latest := latestEntries(entries)
+
+	if len(latest) != 2 {
+		t.Fatalf("got %d entries, want 2", len(latest))
+	}
+	if got := latest["abc"]; got != entries[0] {
+		t.Errorf("abc - got: %+v, want the completed analysis", got)
+	}
+	if got := latest["def"].posted(); got != "" {
+		t.Errorf("def - got posted: %q, want nothing, since the comment was dropped", got)
+	}
+
+	r := &replayed{uuid: "def", original: latest["def"], smells: []string{"gofmt"}, comment: "gofmt"}
+	if !r.changed() {
+		t.Error("a comment that wasn't posted the first time should show as changed")
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// newServeMux sets up the internal endpoints of rikki-'s embedded HTTP server.
+// The checks decide whether rikki- reports itself as ready. With a store,
+// it also serves snapshots of the database to commands.
+func newServeMux(checks []check, store *store) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthz)
+	mux.Handle("/readyz", readyz(checks))
+	if store != nil {
+		mux.Handle("/db", store)
+	}
+	return mux
+}
+
+// newVotesMux sets up the public endpoint where students vote on comments.
+func newVotesMux(votes *votes) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/vote", votes)
+	return mux
+}
+
+// serve runs the embedded HTTP server in the background.
+// An empty address disables it.
+func serve(addr string, handler http.Handler) {
+	if addr == "" {
+		return
+	}
+	go func() {
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			lgr.WithError(err).WithField("addr", addr).Error("http server stopped")
+		}
+	}()
+}
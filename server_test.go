@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	observeAPI("submit_comment", time.Now(), http.StatusUnauthorized, errors.New("unauthorized"))
+
+	ts := httptest.NewServer(newServeMux(nil, nil))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `rikki_api_errors_total{endpoint="submit_comment",status="401"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("metrics missing %s", want)
+	}
+}
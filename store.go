@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// storeTimeout is how long to wait for another process to finish with the database.
+const storeTimeout = 5 * time.Second
+
+// store keeps rikki-'s records in a local BoltDB file.
+//
+// The worker opens the file once, at startup, and shares it between
+// everything that keeps records. BoltDB locks the file for as long as it is
+// open, so commands that read it while the worker is running, such as
+// reports, read a snapshot that the worker serves instead.
+type store struct {
+	db *bolt.DB
+	// cleanup removes the snapshot, if the store was read from one.
+	cleanup func()
+}
+
+// openStore opens the database for the worker.
+func openStore(path string) (*store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: storeTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s - %s", path, err)
+	}
+	return &store{db: db}, nil
+}
+
+// readStore opens the database for a command that only reads it.
+// If the worker has it open, the command reads the snapshot served at
+// addr instead. A database that doesn't exist yet reads as empty.
+func readStore(path, addr string) (*store, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &store{}, nil
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err == bolt.ErrTimeout && addr != "" {
+		return readSnapshot(addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s - %s", path, err)
+	}
+	return &store{db: db}, nil
+}
+
+// readSnapshot fetches a copy of the database from the worker at addr.
+func readSnapshot(addr string) (*store, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	url := "http://" + net.JoinHostPort(host, port) + "/db"
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("the database is in use, and the worker can't be reached for a snapshot - %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+	}
+
+	f, err := ioutil.TempFile("", "rikki-snapshot")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	_, err = io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	db, err := bolt.Open(f.Name(), 0600, &bolt.Options{Timeout: storeTimeout, ReadOnly: true})
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	return &store{db: db, cleanup: cleanup}, nil
+}
+
+// Close closes the database, and removes the snapshot it was read from.
+func (s *store) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	if s.cleanup != nil {
+		s.cleanup()
+	}
+	return err
+}
+
+// update runs fn in a read-write transaction, creating the bucket if needed.
+func (s *store) update(bucket string, fn func(*bolt.Bucket) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+// view runs fn in a read-only transaction.
+// If nothing has been stored in the bucket yet, fn isn't called.
+func (s *store) view(bucket string, fn func(*bolt.Bucket) error) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return fn(b)
+	})
+}
+
+// ServeHTTP writes a consistent snapshot of the database, for commands
+// that can't open it while the worker has it. The commands run on the same
+// machine, and the snapshot holds the whole audit log and every vote, so it
+// is only served to clients on a loopback address.
+func (s *store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	err = s.db.View(func(tx *bolt.Tx) error {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(tx.Size(), 10))
+		_, err := tx.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		lgr.WithError(err).Error("unable to write database snapshot")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestStore(t *testing.T, path string) *store {
+	s, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestReadStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rikki-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rikki.db")
+
+	// Nothing has been stored yet.
+	empty, err := readStore(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.view("votes", func(*bolt.Bucket) error { t.Error("there should be no bucket"); return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker has the database open, so a command reads its snapshot.
+	worker := newTestStore(t, path)
+	defer worker.Close()
+	err = worker.update("votes", func(b *bolt.Bucket) error { return b.Put([]byte("token"), []byte("helpful")) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(newServeMux(nil, worker))
+	defer ts.Close()
+
+	snapshot, err := readStore(path, strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	err = snapshot.view("votes", func(b *bolt.Bucket) error {
+		got = string(b.Get([]byte("token")))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "helpful" {
+		t.Errorf("got: %q, want: %q", got, "helpful")
+	}
+	if err := snapshot.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreSnapshotOnlyForLoopback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rikki-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := newTestStore(t, filepath.Join(dir, "rikki.db"))
+	defer s.Close()
+
+	tests := []struct {
+		remote string
+		status int
+	}{
+		{"127.0.0.1:4321", http.StatusOK},
+		{"[::1]:4321", http.StatusOK},
+		{"192.0.2.1:4321", http.StatusForbidden},
+		{"[2001:db8::1]:4321", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/db", nil)
+		req.RemoteAddr = test.remote
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%s - got: %d, want: %d", test.remote, rec.Code, test.status)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// Package bob answers like a lackadaisical teenager.
+package bob
+
+import "strings"
+
+// Hey responds to a remark.
+func Hey(remark string) string {
+	trimmed_remark := strings.TrimSpace(remark)
+	if trimmed_remark == "" {
+		return "Fine. Be that way!"
+	}
+	return "Whatever."
+}
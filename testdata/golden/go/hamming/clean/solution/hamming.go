@@ -0,0 +1,18 @@
+// Package hamming counts the differences between DNA strands.
+package hamming
+
+import "errors"
+
+// Distance counts the positions at which a and b differ.
+func Distance(a, b string) (int, error) {
+	if len(a) != len(b) {
+		return 0, errors.New("strands must be of equal length")
+	}
+	count := 0
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			count++
+		}
+	}
+	return count, nil
+}
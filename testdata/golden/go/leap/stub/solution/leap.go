@@ -0,0 +1,2 @@
+// Package leap is a stub.
+package leap
@@ -0,0 +1,7 @@
+// Package leap tells leap years apart.
+package leap
+
+// IsLeapYear reports whether year is a leap year.
+func IsLeapYear(year int) bool {
+return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
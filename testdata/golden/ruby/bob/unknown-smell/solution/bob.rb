@@ -0,0 +1,6 @@
+class Bob
+  def hey(remark)
+    return 'Fine. Be that way!' if remark.strip.empty?
+    'Whatever.'
+  end
+end
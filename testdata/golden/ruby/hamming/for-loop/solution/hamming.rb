@@ -0,0 +1,9 @@
+class Hamming
+  def self.compute(a, b)
+    count = 0
+    for i in 0...a.length
+      count += 1 if a[i] != b[i]
+    end
+    count
+  end
+end
@@ -0,0 +1,11 @@
+class Hamming
+  def self.compute(a, b)
+    count = 0
+    a.chars.each_with_index do |x, i|
+      b.chars.each_with_index do |y, j|
+        count += 1 if i == j && x != y
+      end
+    end
+    count
+  end
+end
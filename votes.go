@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// votesBucket holds a vote per comment, keyed by the comment's token.
+const votesBucket = "votes"
+
+// votes lets students tell us whether a comment helped.
+//
+// Votes are taken on a listener of their own, since students need to reach
+// it and they have no business with metrics or readiness. Each comment links
+// to the votes endpoint with a token that says which submission, smell and
+// variant it was about. The token is signed, so that votes can't be made
+// up for comments that were never posted.
+// A nil votes doesn't link to anything.
+type votes struct {
+	url   string
+	keys  [][]byte
+	store *store
+}
+
+// vote is what a student thought of a comment.
+type vote struct {
+	UUID    string    `json:"uuid"`
+	Track   string    `json:"track"`
+	Smell   string    `json:"smell"`
+	Variant string    `json:"variant,omitempty"`
+	Helpful bool      `json:"helpful"`
+	Time    time.Time `json:"time"`
+}
+
+// newVotes configures votes to be cast at url, which is where students can
+// reach the votes listener. Tokens are signed with a key derived from the
+// current secret, and tokens made with the retired ones are still accepted,
+// so that rotating the secret doesn't break the links in comments that have
+// already been posted.
+func newVotes(url string, auth *Auth, store *store) *votes {
+	v := &votes{url: strings.TrimSuffix(url, "/"), store: store}
+	for _, secret := range append([]string{auth.Secret}, auth.Retired...) {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("rikki-votes"))
+		v.keys = append(v.keys, mac.Sum(nil))
+	}
+	return v
+}
+
+// links returns the URLs for voting a comment helpful or not.
+func (v *votes) links(data footerData) (helpful, unhelpful string) {
+	if v == nil || data.Smell == "" {
+		return "", ""
+	}
+	token := v.token(data)
+	link := func(helpful bool) string {
+		q := neturl.Values{}
+		q.Set("t", token)
+		q.Set("helpful", fmt.Sprint(helpful))
+		return v.url + "/vote?" + q.Encode()
+	}
+	return link(true), link(false)
+}
+
+func (v *votes) token(data footerData) string {
+	payload := strings.Join([]string{data.UUID, data.Track, data.Smell, data.Variant}, "|")
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + sign(v.keys[0], payload)
+}
+
+func sign(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// parse checks a token, and says which comment it is for.
+func (v *votes) parse(token string) (vote, error) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return vote{}, errors.New("malformed token")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return vote{}, errors.New("malformed token")
+	}
+	payload := string(b)
+	valid := false
+	for _, key := range v.keys {
+		if hmac.Equal([]byte(token[i+1:]), []byte(sign(key, payload))) {
+			valid = true
+		}
+	}
+	if !valid {
+		return vote{}, errors.New("invalid token")
+	}
+	fields := strings.Split(payload, "|")
+	if len(fields) != 4 {
+		return vote{}, errors.New("malformed token")
+	}
+	return vote{UUID: fields[0], Track: fields[1], Smell: fields[2], Variant: fields[3]}, nil
+}
+
+// confirmPage asks a student to confirm their vote. Link previews and
+// prefetchers follow the links in comments too, so following one only
+// shows this page, and it's the form that casts the vote.
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>rikki-</title></head>
+<body>
+<form method="post" action="vote">
+<input type="hidden" name="t" value="{{.Token}}">
+<input type="hidden" name="helpful" value="{{.Helpful}}">
+<p>Was the comment about {{.Smell}} helpful?</p>
+<button type="submit">{{if .Helpful}}Yes, it helped{{else}}No, it didn't help{{end}}</button>
+</form>
+</body>
+</html>
+`))
+
+// ServeHTTP takes a vote. Students get here by following a link in a comment,
+// which shows them a page to confirm their vote. Only the confirmation,
+// a POST, records it.
+func (v *votes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.FormValue("t")
+	vt, err := v.parse(token)
+	if err != nil {
+		http.Error(w, "Sorry, that link doesn't work.", http.StatusBadRequest)
+		return
+	}
+	switch r.FormValue("helpful") {
+	case "true":
+		vt.Helpful = true
+	case "false":
+	default:
+		http.Error(w, "Sorry, that link doesn't work.", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "GET" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := struct {
+			Token, Smell string
+			Helpful      bool
+		}{token, vt.Smell, vt.Helpful}
+		if err := confirmPage.Execute(w, data); err != nil {
+			lgr.WithError(err).Error("unable to render vote confirmation")
+		}
+		return
+	}
+
+	vt.Time = time.Now().UTC()
+	if err := v.record(token, vt); err != nil {
+		lgr.WithError(err).Error("unable to record vote")
+		http.Error(w, "Sorry, we couldn't record your vote. Please try again later.", http.StatusInternalServerError)
+		return
+	}
+	lgr.WithFields(logrus.Fields{"uuid": vt.UUID, "track": vt.Track, "smell": vt.Smell, "variant": vt.Variant, "helpful": vt.Helpful}).Info("vote recorded")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "Thanks for letting us know!")
+}
+
+// record stores a vote. A student who changes their mind replaces their vote.
+func (v *votes) record(token string, vt vote) error {
+	b, err := json.Marshal(vt)
+	if err != nil {
+		return err
+	}
+	return v.store.update(votesBucket, func(bucket *bolt.Bucket) error {
+		return bucket.Put([]byte(token), b)
+	})
+}
+
+// tally is how helpful a comment has been found.
+type tally struct {
+	track, smell, variant string
+	helpful, unhelpful    int
+}
+
+// tallyVotes adds up the votes for each smell and variant.
+func tallyVotes(s *store) ([]*tally, error) {
+	tallies := map[string]*tally{}
+	err := s.view(votesBucket, func(bucket *bolt.Bucket) error {
+		return bucket.ForEach(func(_, b []byte) error {
+			var vt vote
+			if err := json.Unmarshal(b, &vt); err != nil {
+				return err
+			}
+			key := vt.Track + "|" + vt.Smell + "|" + vt.Variant
+			t, ok := tallies[key]
+			if !ok {
+				t = &tally{track: vt.Track, smell: vt.Smell, variant: vt.Variant}
+				tallies[key] = t
+			}
+			if vt.Helpful {
+				t.helpful++
+			} else {
+				t.unhelpful++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*tally
+	for _, t := range tallies {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.track != b.track {
+			return a.track < b.track
+		}
+		if a.smell != b.smell {
+			return a.smell < b.smell
+		}
+		return a.variant < b.variant
+	})
+	return list, nil
+}
+
+// printTallies writes a table of how helpful each comment has been found.
+func printTallies(w io.Writer, tallies []*tally) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TRACK\tSMELL\tVARIANT\tHELPFUL\tNOT HELPFUL\tHELPFUL %")
+	for _, t := range tallies {
+		variant := t.variant
+		if variant == "" {
+			variant = "-"
+		}
+		pct := 100 * t.helpful / (t.helpful + t.unhelpful)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d%%\n", t.track, t.smell, variant, t.helpful, t.unhelpful, pct)
+	}
+	return tw.Flush()
+}
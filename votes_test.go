@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestVotes(t *testing.T) (*votes, func()) {
+	dir, err := ioutil.TempDir("", "rikki-votes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newTestStore(t, filepath.Join(dir, "rikki.db"))
+	v := newVotes("http://rikki.example.com/", NewAuth("s3cret", []string{"old"}), store)
+	return v, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestVoteTokens(t *testing.T) {
+	v, cleanup := newTestVotes(t)
+	defer cleanup()
+
+	data := footerData{Track: "go", Smell: "gofmt", Variant: "b", UUID: "abc"}
+	token := v.token(data)
+	vt, err := v.parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vt.UUID != "abc" || vt.Track != "go" || vt.Smell != "gofmt" || vt.Variant != "b" {
+		t.Errorf("unexpected vote %#v", vt)
+	}
+
+	forged := newVotes("http://rikki.example.com", NewAuth("wrong", nil), nil).token(data)
+	if _, err := v.parse(forged); err == nil {
+		t.Error("expected a token signed with the wrong secret to be rejected")
+	}
+	rotated := newVotes("http://rikki.example.com", NewAuth("old", nil), nil).token(data)
+	if _, err := v.parse(rotated); err != nil {
+		t.Errorf("expected a token signed with a retired secret to be accepted, got: %s", err)
+	}
+	if _, err := v.parse("garbage"); err == nil {
+		t.Error("expected a malformed token to be rejected")
+	}
+
+	helpful, unhelpful := v.links(data)
+	if !strings.HasPrefix(helpful, "http://rikki.example.com/vote?") || !strings.Contains(unhelpful, "helpful=false") {
+		t.Errorf("unexpected links %s and %s", helpful, unhelpful)
+	}
+	if helpful, _ := (*votes)(nil).links(data); helpful != "" {
+		t.Errorf("got a link without votes configured: %s", helpful)
+	}
+}
+
+func TestVotesReport(t *testing.T) {
+	v, cleanup := newTestVotes(t)
+	defer cleanup()
+	ts := httptest.NewServer(newVotesMux(v))
+	defer ts.Close()
+
+	// Following the link asks to confirm the vote, and the form posts it.
+	vote := func(data footerData, helpful bool, want int) {
+		h, u := v.links(data)
+		link := u
+		if helpful {
+			link = h
+		}
+		link = strings.Replace(link, "http://rikki.example.com", ts.URL, 1)
+		resp, err := http.Get(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), `method="post"`) {
+			t.Errorf("%s - expected a confirmation page, got status %d:\n%s", link, resp.StatusCode, page)
+		}
+
+		parsed, err := url.Parse(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err = http.PostForm(ts.URL+"/vote", parsed.Query())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s - got status %d, want %d", link, resp.StatusCode, want)
+		}
+	}
+	vote(footerData{Track: "go", Smell: "gofmt", Variant: "a", UUID: "1"}, true, http.StatusOK)
+	vote(footerData{Track: "go", Smell: "gofmt", Variant: "a", UUID: "2"}, false, http.StatusOK)
+	vote(footerData{Track: "go", Smell: "gofmt", Variant: "b", UUID: "3"}, true, http.StatusOK)
+	// Changing your mind replaces your vote.
+	vote(footerData{Track: "ruby", Smell: "for_loop/for_loop", UUID: "4"}, true, http.StatusOK)
+	vote(footerData{Track: "ruby", Smell: "for_loop/for_loop", UUID: "4"}, false, http.StatusOK)
+
+	resp, err := http.PostForm(ts.URL+"/vote", url.Values{"t": {"forged.token"}, "helpful": {"true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("forged token - got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	// Only following a link, as link previews do, doesn't vote.
+	h, _ := v.links(footerData{Track: "go", Smell: "gofmt", Variant: "a", UUID: "5"})
+	resp, err = http.Get(strings.Replace(h, "http://rikki.example.com", ts.URL, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	tallies, err := tallyVotes(v.store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := printTallies(&buf, tallies); err != nil {
+		t.Fatal(err)
+	}
+	want := `TRACK  SMELL              VARIANT  HELPFUL  NOT HELPFUL  HELPFUL %
+go     gofmt              a        1        1            50%
+go     gofmt              b        1        0            100%
+ruby   for_loop/for_loop  -        0        1            0%
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestAnalyzerLinksVotes(t *testing.T) {
+	v, cleanup := newTestVotes(t)
+	defer cleanup()
+
+	analyzer, api := newTestAnalyzer(t)
+	defer api.Close()
+	analyzer.votes = v
+	analyzer.process(newTestMsg(t, "analyze", "stubbed"))
+
+	comments := api.Comments()
+	if len(comments) != 1 {
+		t.Fatalf("got %d comments, want 1", len(comments))
+	}
+	helpful, unhelpful := v.links(footerData{Track: "go", Smell: "stub", UUID: "stubbed"})
+	if !strings.Contains(comments[0].Body, helpful) || !strings.Contains(comments[0].Body, unhelpful) {
+		t.Errorf("expected the comment to link to votes, got %q", comments[0].Body)
+	}
+}